@@ -0,0 +1,200 @@
+package textcurve
+
+import "golang.org/x/text/unicode/bidi"
+
+// Direction is the base direction of paragraphs.
+type Direction int
+
+const (
+	// DirectionAuto uses the direction of the first strong character of
+	// each paragraph, defaulting to left-to-right (UAX #9 rules P2 and P3).
+	DirectionAuto Direction = iota
+	// DirectionLTR lays out paragraphs left-to-right.
+	DirectionLTR
+	// DirectionRTL lays out paragraphs right-to-left.
+	DirectionRTL
+)
+
+// paragraphRTL reports whether a paragraph is laid out right-to-left.
+func paragraphRTL(runes []rune, dir Direction) bool {
+	switch dir {
+	case DirectionLTR:
+		return false
+	case DirectionRTL:
+		return true
+	}
+	isolates := 0
+	for _, r := range runes {
+		props, _ := bidi.LookupRune(r)
+		switch props.Class() {
+		case bidi.LRI, bidi.RLI, bidi.FSI:
+			isolates++
+		case bidi.PDI:
+			if isolates > 0 {
+				isolates--
+			}
+		case bidi.L:
+			if isolates == 0 {
+				return false
+			}
+		case bidi.R, bidi.AL:
+			if isolates == 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// bidiLevels resolves the embedding level of each rune of a line with the
+// Unicode Bidirectional Algorithm (UAX #9).
+//
+// x/text/unicode/bidi only reports the direction of each run, so levels are
+// reconstructed from it: in a left-to-right paragraph, numbers following
+// right-to-left text are raised to level 2 (rule I1). Explicit embeddings
+// and isolates are resolved, but flattened to these levels.
+func bidiLevels(runes []rune, rtl bool) []uint8 {
+	base := uint8(0)
+	if rtl {
+		base = 1
+	}
+	levels := make([]uint8, len(runes))
+	for i := range levels {
+		levels[i] = base
+	}
+	if len(runes) == 0 {
+		return levels
+	}
+
+	// A leading mark forces the paragraph direction, since the package
+	// only supports detecting it from the text.
+	mark := '\u200e'
+	if rtl {
+		mark = '\u200f'
+	}
+	var p bidi.Paragraph
+	if _, err := p.SetString(string(mark) + string(runes)); err != nil {
+		return levels
+	}
+	order, err := p.Order()
+	if err != nil {
+		return levels
+	}
+	odd := make([]bool, len(runes))
+	for i := 0; i < order.NumRuns(); i++ {
+		run := order.Run(i)
+		start, end := run.Pos()
+		for j := max(start, 1); j <= end && j <= len(runes); j++ {
+			odd[j-1] = run.Direction() == bidi.RightToLeft
+		}
+	}
+
+	classes := make([]bidi.Class, len(runes))
+	for i, r := range runes {
+		props, _ := bidi.LookupRune(r)
+		classes[i] = props.Class()
+	}
+	lastStrongRTL := rtl
+	for i, c := range classes {
+		switch c {
+		case bidi.L:
+			lastStrongRTL = false
+		case bidi.R, bidi.AL:
+			lastStrongRTL = true
+		}
+		if odd[i] {
+			levels[i] = base | 1
+		} else if rtl {
+			levels[i] = base + 1
+		} else if isBidiNumber(classes, i) && (c == bidi.AN || lastStrongRTL) {
+			levels[i] = 2
+		}
+	}
+
+	// Rule L1: trailing whitespace takes the paragraph level.
+	for i := len(runes) - 1; i >= 0; i-- {
+		switch classes[i] {
+		case bidi.WS, bidi.S, bidi.B, bidi.BN, bidi.LRI, bidi.RLI, bidi.FSI, bidi.PDI:
+			levels[i] = base
+			continue
+		}
+		break
+	}
+	return levels
+}
+
+// isBidiNumber reports whether the rune at i resolves to a number type
+// (rules W1, W4 and W5).
+func isBidiNumber(classes []bidi.Class, i int) bool {
+	isDigit := func(j int) bool {
+		return j >= 0 && j < len(classes) && (classes[j] == bidi.EN || classes[j] == bidi.AN)
+	}
+	switch classes[i] {
+	case bidi.EN, bidi.AN:
+		return true
+	case bidi.NSM:
+		return i > 0 && isBidiNumber(classes, i-1)
+	case bidi.ES, bidi.CS:
+		return isDigit(i-1) && isDigit(i+1) && classes[i-1] == classes[i+1]
+	case bidi.ET:
+		j := i
+		for j > 0 && classes[j-1] == bidi.ET {
+			j--
+		}
+		if j > 0 && classes[j-1] == bidi.EN {
+			return true
+		}
+		j = i
+		for j+1 < len(classes) && classes[j+1] == bidi.ET {
+			j++
+		}
+		return j+1 < len(classes) && classes[j+1] == bidi.EN
+	}
+	return false
+}
+
+// levelRun is a maximal range of runes with the same embedding level.
+type levelRun struct {
+	start, end int
+	level      uint8
+}
+
+func (l levelRun) rtl() bool {
+	return l.level%2 == 1
+}
+
+// visualRuns splits a line into level runs and orders them visually, from
+// left to right (rule L2).
+func visualRuns(levels []uint8) []levelRun {
+	var runs []levelRun
+	var maxLevel uint8
+	minOdd := uint8(255)
+	for i, level := range levels {
+		if n := len(runs); n > 0 && runs[n-1].level == level {
+			runs[n-1].end = i + 1
+		} else {
+			runs = append(runs, levelRun{start: i, end: i + 1, level: level})
+		}
+		maxLevel = max(maxLevel, level)
+		if level%2 == 1 {
+			minOdd = min(minOdd, level)
+		}
+	}
+	for level := maxLevel; level >= minOdd && level > 0; level-- {
+		for i := 0; i < len(runs); {
+			if runs[i].level < level {
+				i++
+				continue
+			}
+			j := i
+			for j < len(runs) && runs[j].level >= level {
+				j++
+			}
+			for a, b := i, j-1; a < b; a, b = a+1, b-1 {
+				runs[a], runs[b] = runs[b], runs[a]
+			}
+			i = j
+		}
+	}
+	return runs
+}
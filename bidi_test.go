@@ -0,0 +1,107 @@
+package textcurve
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBidiLevels(t *testing.T) {
+	cases := []struct {
+		text     string
+		rtl      bool
+		expected []uint8
+	}{
+		{"abc", false, []uint8{0, 0, 0}},
+		{"abc", true, []uint8{2, 2, 2}},
+		{"ab אב", false, []uint8{0, 0, 0, 1, 1}},
+		{"ab אב", true, []uint8{2, 2, 1, 1, 1}},
+		{"אב 12", true, []uint8{1, 1, 1, 2, 2}},
+		{"ab אב 12", false, []uint8{0, 0, 0, 1, 1, 1, 2, 2}},
+		{"ab אב ", false, []uint8{0, 0, 0, 1, 1, 0}},
+	}
+	for _, c := range cases {
+		actual := bidiLevels([]rune(c.text), c.rtl)
+		if !reflect.DeepEqual(actual, c.expected) {
+			t.Errorf("%q (rtl=%v): expected %v but got %v", c.text, c.rtl, c.expected, actual)
+		}
+	}
+}
+
+func TestVisualRuns(t *testing.T) {
+	runs := visualRuns([]uint8{0, 0, 1, 1, 2, 2, 1, 0})
+	expected := []levelRun{
+		{start: 0, end: 2, level: 0},
+		{start: 6, end: 7, level: 1},
+		{start: 4, end: 6, level: 2},
+		{start: 2, end: 4, level: 1},
+		{start: 7, end: 8, level: 0},
+	}
+	if !reflect.DeepEqual(runs, expected) {
+		t.Errorf("expected %v but got %v", expected, runs)
+	}
+}
+
+func TestParagraphRTL(t *testing.T) {
+	cases := []struct {
+		text     string
+		expected bool
+	}{
+		{"abc אב", false},
+		{"123 אב abc", true},
+		{"⁧abc⁩ אב", true},
+		{"123", false},
+	}
+	for _, c := range cases {
+		if actual := paragraphRTL([]rune(c.text), DirectionAuto); actual != c.expected {
+			t.Errorf("%q: expected %v but got %v", c.text, c.expected, actual)
+		}
+	}
+	if !paragraphRTL([]rune("abc"), DirectionRTL) || paragraphRTL([]rune("אב"), DirectionLTR) {
+		t.Error("explicit direction was not respected")
+	}
+}
+
+func TestBidiLayout(t *testing.T) {
+	font := parseTestFont(t)
+
+	clusters := func(text string, opt Options) []int {
+		layout, err := layoutText([]*ParsedFont{font}, text, opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var res []int
+		for _, g := range layout.lines[0].glyphs {
+			res = append(res, g.cluster)
+		}
+		return res
+	}
+	opt := Options{Size: 10}
+	if actual, expected := clusters("abc אבג", opt), []int{0, 1, 2, 3, 6, 5, 4}; !reflect.DeepEqual(actual, expected) {
+		t.Errorf("LTR paragraph: expected clusters %v but got %v", expected, actual)
+	}
+	if actual, expected := clusters("אבג abc", opt), []int{4, 5, 6, 3, 2, 1, 0}; !reflect.DeepEqual(actual, expected) {
+		t.Errorf("RTL paragraph: expected clusters %v but got %v", expected, actual)
+	}
+
+	// Start and end alignment follow the paragraph direction.
+	for _, text := range []string{"abc", "אבג"} {
+		rtl := paragraphRTL([]rune(text), DirectionAuto)
+		for _, h := range []HAlign{HAlignStart, HAlignEnd} {
+			opt := Options{Size: 10, Align: Align{HAlign: h}}
+			actual, err := TextOutlines(font, text, opt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			expectedAlign := HAlignLeft
+			if (h == HAlignStart) == rtl {
+				expectedAlign = HAlignRight
+			}
+			opt.Align.HAlign = expectedAlign
+			expected, err := TextOutlines(font, text, opt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertSameBounds(t, text, actual, expected, 0)
+		}
+	}
+}
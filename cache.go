@@ -0,0 +1,143 @@
+package textcurve
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/golang/freetype/truetype"
+	"github.com/unixpickle/model3d/model2d"
+)
+
+// GlyphCacheStats reports the usage of a font's glyph cache.
+type GlyphCacheStats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+
+	Len      int // number of cached glyphs
+	Capacity int
+}
+
+// EnableGlyphCache makes the font keep up to maxGlyphs flattened glyphs in
+// memory, evicting the least recently used glyph when full.
+//
+// Glyphs are cached per glyph index, CurveSegs and variation instance, and
+// reused across sizes and positions. The cache is safe for concurrent use,
+// so one ParsedFont may be shared by many goroutines.
+//
+// Calling EnableGlyphCache again replaces the cache, and a maxGlyphs of 0
+// disables caching. It should not be called concurrently with text layout.
+func (p *ParsedFont) EnableGlyphCache(maxGlyphs int) {
+	if maxGlyphs <= 0 {
+		p.cache = nil
+	} else {
+		p.cache = newGlyphCache(maxGlyphs)
+	}
+}
+
+// GlyphCacheStats returns the statistics of the glyph cache, or zero stats
+// if caching is disabled.
+func (p *ParsedFont) GlyphCacheStats() GlyphCacheStats {
+	if p.cache == nil {
+		return GlyphCacheStats{}
+	}
+	return p.cache.stats()
+}
+
+type glyphCacheKey struct {
+	index      truetype.Index
+	segs       int
+	variations string
+}
+
+type glyphCacheEntry struct {
+	key      glyphCacheKey
+	contours []Contour // in font units, with no pen offset
+	ok       bool
+}
+
+// glyphCache is an LRU cache of flattened glyphs.
+type glyphCache struct {
+	lock     sync.Mutex
+	capacity int
+	entries  map[glyphCacheKey]*list.Element
+	order    *list.List // most recently used first
+
+	hits, misses, evictions uint64
+}
+
+func newGlyphCache(capacity int) *glyphCache {
+	return &glyphCache{
+		capacity: capacity,
+		entries:  map[glyphCacheKey]*list.Element{},
+		order:    list.New(),
+	}
+}
+
+// get looks up a glyph. The returned contours are shared and must not be
+// modified.
+func (g *glyphCache) get(key glyphCacheKey) (contours []Contour, ok, found bool) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	elem, found := g.entries[key]
+	if !found {
+		g.misses++
+		return nil, false, false
+	}
+	g.hits++
+	g.order.MoveToFront(elem)
+	entry := elem.Value.(*glyphCacheEntry)
+	return entry.contours, entry.ok, true
+}
+
+func (g *glyphCache) put(key glyphCacheKey, contours []Contour, ok bool) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if elem, found := g.entries[key]; found {
+		// Another goroutine loaded the same glyph concurrently.
+		g.order.MoveToFront(elem)
+		return
+	}
+	g.entries[key] = g.order.PushFront(&glyphCacheEntry{key: key, contours: contours, ok: ok})
+	for g.order.Len() > g.capacity {
+		oldest := g.order.Back()
+		g.order.Remove(oldest)
+		delete(g.entries, oldest.Value.(*glyphCacheEntry).key)
+		g.evictions++
+	}
+}
+
+func (g *glyphCache) stats() GlyphCacheStats {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	return GlyphCacheStats{
+		Hits:      g.hits,
+		Misses:    g.misses,
+		Evictions: g.evictions,
+		Len:       g.order.Len(),
+		Capacity:  g.capacity,
+	}
+}
+
+// cachedGlyphContours is like loadGlyphContours, but goes through the
+// glyph cache.
+func (p *ParsedFont) cachedGlyphContours(idx truetype.Index, penX, scale float64,
+	segs int) ([]Contour, bool) {
+	key := glyphCacheKey{index: idx, segs: segs, variations: p.variationKey}
+	contours, ok, found := p.cache.get(key)
+	if !found {
+		contours, ok = p.loadGlyphContours(idx, 0, 1, segs)
+		p.cache.put(key, contours, ok)
+	}
+	if !ok {
+		return nil, false
+	}
+	res := make([]Contour, len(contours))
+	for i, c := range contours {
+		res[i] = make(Contour, len(c))
+		for j, pt := range c {
+			res[i][j] = model2d.XY((pt.X+penX)*scale, pt.Y*scale)
+		}
+	}
+	return res, true
+}
@@ -0,0 +1,66 @@
+package textcurve
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestGlyphCache(t *testing.T) {
+	font := parseTestFont(t)
+	opt := Options{Size: 10, Kerning: true}
+	expected, err := TextOutlines(font, "Hello", opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	font.EnableGlyphCache(3)
+	defer font.EnableGlyphCache(0)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				actual, err := TextOutlines(font, "Hello", opt)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if len(actual) != len(expected) {
+					t.Errorf("expected %d contours but got %d", len(expected), len(actual))
+					return
+				}
+				for k, c := range actual {
+					for l, p := range c {
+						if p.Dist(expected[k][l]) > 1e-8 {
+							t.Errorf("contour %d point %d: expected %v but got %v",
+								k, l, expected[k][l], p)
+							return
+						}
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	stats := font.GlyphCacheStats()
+	if stats.Hits+stats.Misses != 8*10*5 {
+		t.Errorf("unexpected number of lookups: %+v", stats)
+	}
+	if stats.Len != 3 || stats.Capacity != 3 || stats.Evictions == 0 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+
+	// Flattening parameters are part of the key.
+	font.EnableGlyphCache(10)
+	for _, segs := range []int{4, 8, 4} {
+		if _, err := TextOutlines(font, "l", Options{Size: 10, CurveSegs: segs}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if stats := font.GlyphCacheStats(); stats.Hits != 1 || stats.Misses != 2 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
@@ -0,0 +1,275 @@
+package textcurve
+
+import (
+	"math"
+	"sort"
+
+	"github.com/go-text/typesetting/segmenter"
+	"github.com/unixpickle/model3d/model2d"
+)
+
+// Layout is laid out text, which maps between offsets in the text and
+// positions in the output of TextOutlines.
+//
+// All offsets are rune offsets into the laid out string.
+type Layout struct {
+	opt     Options
+	text    []rune
+	lines   []layoutLine
+	offsets []model2d.Coord // translation of each line
+	glyphs  []Glyph
+	boxes   [][]graphemeBox // per line, in logical order
+}
+
+// Caret is a caret position at a grapheme cluster boundary.
+type Caret struct {
+	// Offset is the rune offset of the boundary in the text.
+	Offset int
+
+	// Line is the index of the caret's line, or column in vertical text.
+	Line int
+
+	// Pos is the caret position on the baseline of its line, in model
+	// units.
+	Pos model2d.Coord
+}
+
+// graphemeBox is the extent of a grapheme cluster along its line.
+type graphemeBox struct {
+	start, end  int     // rune offsets in the text
+	lead, trail float64 // caret positions before and after the grapheme
+}
+
+// NewLayout lays out text like TextOutlines.
+//
+// The font is a *ParsedFont, or a *FontSet for glyph fallback.
+func NewLayout(font FontSource, s string, opt Options) (*Layout, error) {
+	fonts, err := sourceFonts(font)
+	if err != nil {
+		return nil, err
+	}
+	layout, err := layoutText(fonts, s, opt)
+	if err != nil {
+		return nil, err
+	}
+	glyphs, offsets := layout.glyphs()
+	res := &Layout{
+		opt:     layout.opt,
+		text:    []rune(s),
+		lines:   layout.lines,
+		offsets: offsets,
+		glyphs:  glyphs,
+	}
+	start := 0
+	for i := range res.lines {
+		end := start
+		for end < len(glyphs) && glyphs[end].Line == i {
+			end++
+		}
+		res.boxes = append(res.boxes, res.graphemeBoxes(i, glyphs[start:end]))
+		start = end
+	}
+	return res, nil
+}
+
+// Glyphs returns the glyphs of the layout, as returned by TextGlyphs.
+//
+// The result is shared by all callers and should not be modified.
+func (l *Layout) Glyphs() []Glyph {
+	return l.glyphs
+}
+
+// Carets returns a caret for every grapheme cluster boundary of every line,
+// in logical order, including the start and end of each line.
+//
+// A caret is placed at the leading edge of the grapheme which follows it,
+// i.e. on its right side in right-to-left runs, and the caret at the end of
+// a line is placed at the trailing edge of the last grapheme. Ligatures are
+// split evenly between their graphemes.
+func (l *Layout) Carets() []Caret {
+	var res []Caret
+	for i, line := range l.lines {
+		boxes := l.boxes[i]
+		if len(boxes) == 0 {
+			res = append(res, Caret{Offset: line.start, Line: i, Pos: l.linePoint(i, 0)})
+			continue
+		}
+		for _, box := range boxes {
+			res = append(res, Caret{Offset: box.start, Line: i, Pos: l.linePoint(i, box.lead)})
+		}
+		last := boxes[len(boxes)-1]
+		res = append(res, Caret{Offset: line.end, Line: i, Pos: l.linePoint(i, last.trail)})
+	}
+	return res
+}
+
+// HitTest returns the offset of the grapheme cluster boundary nearest to a
+// point, e.g. to place a caret where the text was clicked.
+//
+// The point is assigned to the line whose outlines are closest across the
+// line, and then to the nearest edge of the grapheme under it along the
+// line, which may be on either side of the grapheme in right-to-left runs.
+func (l *Layout) HitTest(p model2d.Coord) int {
+	if len(l.lines) == 0 {
+		return 0
+	}
+	across, along := p.Y, p.X
+	if l.opt.Vertical {
+		across, along = p.X, -p.Y
+	}
+
+	bestLine, bestDist := 0, math.Inf(1)
+	for i := range l.lines {
+		minAcross, maxAcross := l.lineExtent(i)
+		dist := math.Max(0, math.Max(minAcross-across, across-maxAcross))
+		if dist < bestDist {
+			bestLine, bestDist = i, dist
+		}
+	}
+
+	boxes := l.boxes[bestLine]
+	if len(boxes) == 0 {
+		return l.lines[bestLine].start
+	}
+	var best graphemeBox
+	bestDist = math.Inf(1)
+	for _, box := range boxes {
+		lo, hi := math.Min(box.lead, box.trail), math.Max(box.lead, box.trail)
+		dist := math.Max(0, math.Max(lo-along, along-hi))
+		if dist < bestDist {
+			best, bestDist = box, dist
+		}
+	}
+	if math.Abs(along-best.lead) <= math.Abs(along-best.trail) {
+		return best.start
+	}
+	return best.end
+}
+
+// lineExtent returns the extent of a line's outlines across the line, or
+// its baseline if it has none.
+func (l *Layout) lineExtent(line int) (float64, float64) {
+	origin := l.linePoint(line, 0)
+	minAcross, maxAcross := origin.Y, origin.Y
+	if l.opt.Vertical {
+		minAcross, maxAcross = origin.X, origin.X
+	}
+	first := true
+	for _, g := range l.glyphs {
+		if g.Line != line || len(g.Outlines) == 0 {
+			continue
+		}
+		lo, hi := g.Min.Y, g.Max.Y
+		if l.opt.Vertical {
+			lo, hi = g.Min.X, g.Max.X
+		}
+		if first {
+			minAcross, maxAcross = lo, hi
+			first = false
+		} else {
+			minAcross, maxAcross = math.Min(minAcross, lo), math.Max(maxAcross, hi)
+		}
+	}
+	return minAcross, maxAcross
+}
+
+// linePoint maps a position along a line to a point on its baseline.
+func (l *Layout) linePoint(line int, along float64) model2d.Coord {
+	offset := l.offsets[line]
+	if l.opt.Vertical {
+		return model2d.XY(offset.X, -along)
+	}
+	return model2d.XY(along, offset.Y)
+}
+
+// graphemeBoxes computes the extent of each grapheme cluster of a line from
+// the line's glyphs.
+//
+// Graphemes which share a shaping cluster, like the letters of a ligature,
+// share its advance evenly, and a grapheme made of several clusters spans
+// all of them.
+func (l *Layout) graphemeBoxes(line int, glyphs []Glyph) []graphemeBox {
+	type clusterExtent struct {
+		start, end int
+		lo, hi     float64
+		rtl        bool
+	}
+	var clusters []*clusterExtent
+	byStart := map[int]*clusterExtent{}
+	for _, g := range glyphs {
+		lo := g.Pen.X
+		if l.opt.Vertical {
+			lo = -g.Pen.Y
+		}
+		c, ok := byStart[g.Start]
+		if !ok {
+			c = &clusterExtent{start: g.Start, end: g.End, lo: lo, hi: lo, rtl: g.RTL}
+			byStart[g.Start] = c
+			clusters = append(clusters, c)
+		}
+		c.lo = math.Min(c.lo, lo)
+		c.hi = math.Max(c.hi, lo+g.Advance)
+	}
+	sort.Slice(clusters, func(i, j int) bool {
+		return clusters[i].start < clusters[j].start
+	})
+
+	lineStart, lineEnd := l.lines[line].start, l.lines[line].end
+	if lineStart == lineEnd {
+		return nil
+	}
+	var graphemeEnds []int
+	var seg segmenter.Segmenter
+	seg.Init(l.text[lineStart:lineEnd])
+	iter := seg.GraphemeIterator()
+	for iter.Next() {
+		g := iter.Grapheme()
+		graphemeEnds = append(graphemeEnds, lineStart+g.Offset+len(g.Text))
+	}
+
+	var res []graphemeBox
+	start, pos := lineStart, 0.0
+	graphemeIdx, clusterIdx := 0, 0
+	for graphemeIdx < len(graphemeEnds) {
+		// Merge graphemes and clusters until their boundaries agree.
+		ends := []int{graphemeEnds[graphemeIdx]}
+		graphemeIdx++
+		end := ends[0]
+		lo, hi := math.Inf(1), math.Inf(-1)
+		rtl := false
+		for {
+			for clusterIdx < len(clusters) && clusters[clusterIdx].start < end {
+				c := clusters[clusterIdx]
+				if lo > hi {
+					rtl = c.rtl
+				}
+				lo, hi = math.Min(lo, c.lo), math.Max(hi, c.hi)
+				end = max(end, c.end)
+				clusterIdx++
+			}
+			if graphemeIdx == len(graphemeEnds) || ends[len(ends)-1] >= end {
+				break
+			}
+			ends = append(ends, graphemeEnds[graphemeIdx])
+			graphemeIdx++
+			end = max(end, ends[len(ends)-1])
+		}
+		if lo > hi {
+			lo, hi = pos, pos
+		}
+
+		width := (hi - lo) / float64(len(ends))
+		for i, e := range ends {
+			box := graphemeBox{start: start, end: e}
+			if rtl {
+				box.lead, box.trail = hi-float64(i)*width, hi-float64(i+1)*width
+			} else {
+				box.lead, box.trail = lo+float64(i)*width, lo+float64(i+1)*width
+			}
+			res = append(res, box)
+			start = e
+		}
+		pos = res[len(res)-1].trail
+	}
+	return res
+}
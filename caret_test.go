@@ -0,0 +1,122 @@
+package textcurve
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/unixpickle/model3d/model2d"
+)
+
+func TestLayoutCarets(t *testing.T) {
+	font := parseTestFont(t)
+
+	layout, err := NewLayout(font, "ab\ncd", Options{Size: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	carets := layout.Carets()
+	var offsets []int
+	for _, c := range carets {
+		offsets = append(offsets, c.Offset)
+	}
+	if expected := []int{0, 1, 2, 3, 4, 5}; !reflect.DeepEqual(offsets, expected) {
+		t.Fatalf("expected offsets %v but got %v", expected, offsets)
+	}
+	glyphs := layout.Glyphs()
+	lineEnd := func(g Glyph) model2d.Coord {
+		return g.Pen.Add(model2d.XY(g.Advance, 0))
+	}
+	expectedPos := []model2d.Coord{
+		glyphs[0].Pen, glyphs[1].Pen, lineEnd(glyphs[1]),
+		glyphs[2].Pen, glyphs[3].Pen, lineEnd(glyphs[3]),
+	}
+	for i, c := range carets {
+		expected := expectedPos[i]
+		if c.Pos.Dist(expected) > 1e-8 {
+			t.Errorf("caret %d: expected %v but got %v", i, expected, c.Pos)
+		}
+	}
+
+	for i, c := range carets {
+		if actual := layout.HitTest(c.Pos.Add(model2d.XY(0.1, 2))); actual != c.Offset {
+			t.Errorf("caret %d: hit test returned %d instead of %d", i, actual, c.Offset)
+		}
+	}
+	if actual := layout.HitTest(model2d.XY(-100, 100)); actual != 0 {
+		t.Errorf("expected 0 but got %d", actual)
+	}
+	if actual := layout.HitTest(model2d.XY(100, -100)); actual != 5 {
+		t.Errorf("expected 5 but got %d", actual)
+	}
+
+	setLayout, err := NewLayout(NewFontSet(font), "ab\ncd", Options{Size: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if setCarets := setLayout.Carets(); !reflect.DeepEqual(setCarets, carets) {
+		t.Errorf("expected carets %v for font set but got %v", carets, setCarets)
+	}
+
+	// Carets in right-to-left runs move leftwards.
+	layout, err = NewLayout(font, "ab אבג", Options{Size: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	carets = layout.Carets()
+	if len(carets) != 7 {
+		t.Fatalf("expected 7 carets but got %d", len(carets))
+	}
+	for i := 0; i < 3; i++ {
+		if carets[i+1].Pos.X <= carets[i].Pos.X {
+			t.Errorf("caret %d should be right of caret %d", i+1, i)
+		}
+	}
+	for i := 3; i < 5; i++ {
+		if carets[i+1].Pos.X >= carets[i].Pos.X {
+			t.Errorf("caret %d should be left of caret %d", i+1, i)
+		}
+	}
+	for _, c := range carets[3:6] {
+		if actual := layout.HitTest(c.Pos.Add(model2d.XY(-0.1, 2))); actual != c.Offset {
+			t.Errorf("hit test returned %d instead of %d", actual, c.Offset)
+		}
+	}
+}
+
+func TestGraphemeBoxes(t *testing.T) {
+	layout := &Layout{
+		text:    []rune("xfié"),
+		lines:   []layoutLine{{start: 1, end: 5}},
+		offsets: []model2d.Coord{{}},
+	}
+	glyphs := []Glyph{
+		// A ligature of two letters.
+		{Start: 1, End: 3, Pen: model2d.XY(0, 0), Advance: 10},
+		// A letter and a mark in separate clusters.
+		{Start: 3, End: 4, Pen: model2d.XY(10, 0), Advance: 6},
+		{Start: 4, End: 5, Pen: model2d.XY(13, 0)},
+	}
+	boxes := layout.graphemeBoxes(0, glyphs)
+	expected := []graphemeBox{
+		{start: 1, end: 2, lead: 0, trail: 5},
+		{start: 2, end: 3, lead: 5, trail: 10},
+		{start: 3, end: 5, lead: 10, trail: 16},
+	}
+	if !reflect.DeepEqual(boxes, expected) {
+		t.Errorf("expected %v but got %v", expected, boxes)
+	}
+
+	for i := range glyphs {
+		glyphs[i].RTL = true
+		glyphs[i].Pen.X = 16 - glyphs[i].Pen.X - glyphs[i].Advance
+	}
+	boxes = layout.graphemeBoxes(0, glyphs)
+	for i, box := range boxes {
+		e := expected[i]
+		if box.start != e.start || box.end != e.end || math.Abs(box.lead-(16-e.lead)) > 1e-8 ||
+			math.Abs(box.trail-(16-e.trail)) > 1e-8 {
+			t.Errorf("box %d: unexpected RTL box %v", i, box)
+		}
+	}
+}
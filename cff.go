@@ -0,0 +1,82 @@
+package textcurve
+
+import (
+	gotextfont "github.com/go-text/typesetting/font"
+	ot "github.com/go-text/typesetting/font/opentype"
+	"github.com/unixpickle/model3d/model2d"
+)
+
+// glyphOutlineToPolylines converts go-text outline segments (as decoded from
+// CFF/CFF2 charstrings) into flattened polylines.
+// penX is in font units; scale maps font units -> model units.
+//
+// PostScript outlines wind counter-clockwise around filled regions, which is
+// the opposite of TrueType. If reverse is set, contours are reversed so that
+// callers see the same orientation regardless of the outline format.
+func glyphOutlineToPolylines(outline gotextfont.GlyphOutline, penX, scale float64, segs int,
+	reverse bool) []Contour {
+	toVec := func(p ot.SegmentPoint) model2d.Coord {
+		x := (float64(p.X) + penX) * scale
+		y := float64(p.Y) * scale
+		return model2d.Coord{X: x, Y: y}
+	}
+
+	var out []Contour
+	var poly Contour
+	flush := func() {
+		if len(poly) == 0 {
+			return
+		}
+		if poly[len(poly)-1] != poly[0] {
+			poly = append(poly, poly[0])
+		}
+		if len(poly) >= 4 {
+			if reverse {
+				for i, j := 0, len(poly)-1; i < j; i, j = i+1, j-1 {
+					poly[i], poly[j] = poly[j], poly[i]
+				}
+			}
+			out = append(out, poly)
+		}
+		poly = nil
+	}
+
+	var last model2d.Coord
+	for _, seg := range outline.Segments {
+		switch seg.Op {
+		case ot.SegmentOpMoveTo:
+			flush()
+			last = toVec(seg.Args[0])
+			poly = Contour{last}
+			continue
+		case ot.SegmentOpLineTo:
+			p := toVec(seg.Args[0])
+			if p != last {
+				poly = append(poly, p)
+			}
+			last = p
+		case ot.SegmentOpQuadTo:
+			p := toVec(seg.Args[1])
+			poly = append(poly, flattenQuad(last, toVec(seg.Args[0]), p, segs)...)
+			last = p
+		case ot.SegmentOpCubeTo:
+			p := toVec(seg.Args[2])
+			poly = append(poly, flattenCubic(last, toVec(seg.Args[0]), toVec(seg.Args[1]), p, segs)...)
+			last = p
+		}
+	}
+	flush()
+
+	return out
+}
+
+func flattenCubic(p0, p1, p2, p3 model2d.Coord, segs int) []model2d.Coord {
+	out := make([]model2d.Coord, 0, segs)
+	for i := 1; i <= segs; i++ {
+		t := float64(i) / float64(segs)
+		u := 1 - t
+		p := p0.Scale(u * u * u).Add(p1.Scale(3 * u * u * t)).Add(p2.Scale(3 * u * t * t)).Add(p3.Scale(t * t * t))
+		out = append(out, p)
+	}
+	return out
+}
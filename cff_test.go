@@ -0,0 +1,155 @@
+package textcurve
+
+import (
+	"image/color"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	gotextfont "github.com/go-text/typesetting/font"
+	ot "github.com/go-text/typesetting/font/opentype"
+	"github.com/unixpickle/model3d/model2d"
+)
+
+func TestGlyphOutlineToPolylines(t *testing.T) {
+	outline := gotextfont.GlyphOutline{
+		Segments: []gotextfont.Segment{
+			{Op: ot.SegmentOpMoveTo, Args: [3]ot.SegmentPoint{{X: 0, Y: 0}}},
+			{Op: ot.SegmentOpLineTo, Args: [3]ot.SegmentPoint{{X: 100, Y: 0}}},
+			{Op: ot.SegmentOpCubeTo, Args: [3]ot.SegmentPoint{{X: 150, Y: 50}, {X: 150, Y: 100}, {X: 100, Y: 150}}},
+			{Op: ot.SegmentOpLineTo, Args: [3]ot.SegmentPoint{{X: 0, Y: 150}}},
+		},
+	}
+	contours := glyphOutlineToPolylines(outline, 10, 0.5, 4, false)
+	if len(contours) != 1 {
+		t.Fatalf("expected 1 contour, got %d", len(contours))
+	}
+	c := contours[0]
+	if c[0] != c[len(c)-1] {
+		t.Fatalf("contour is not closed")
+	}
+	if c[0] != model2d.XY(5, 0) {
+		t.Fatalf("unexpected start %v", c[0])
+	}
+	// Start, line end, four cubic samples, line end, closing point.
+	if len(c) != 8 {
+		t.Fatalf("expected 8 points, got %d", len(c))
+	}
+	if c[5] != model2d.XY(55, 75) {
+		t.Fatalf("unexpected cubic end %v", c[5])
+	}
+
+	reversed := glyphOutlineToPolylines(outline, 10, 0.5, 4, true)[0]
+	for i := range c {
+		if reversed[i] != c[len(c)-1-i] {
+			t.Fatalf("reversed contour mismatch at %d", i)
+		}
+	}
+}
+
+func TestOutlineBackendParity(t *testing.T) {
+	font := parseTestFont(t)
+	goTextFont := *font
+	goTextFont.TTFont = nil
+
+	opt := Options{Size: 10, CurveSegs: 8, Kerning: true}
+	for _, s := range testStrings {
+		expected, err := TextOutlines(font, s, opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		actual, err := TextOutlines(&goTextFont, s, opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(actual) != len(expected) {
+			t.Fatalf("%q: expected %d contours, got %d", s, len(expected), len(actual))
+		}
+		min1, max1 := outlinesBounds(expected)
+		min2, max2 := outlinesBounds(actual)
+		if min1.Dist(min2) > 1e-3 || max1.Dist(max2) > 1e-3 {
+			t.Errorf("%q: bounds mismatch: %v-%v vs %v-%v", s, min1, max1, min2, max2)
+		}
+	}
+}
+
+func TestCFFFont(t *testing.T) {
+	fontBytes, err := os.ReadFile(filepath.Join("test_data", "CFFTest.otf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	font, err := ParseTTF(fontBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if font.TTFont != nil {
+		t.Fatal("expected CFF font to use the go-text backend")
+	}
+
+	glyphs, err := TextGlyphs(font, "01Q", Options{Size: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(glyphs) != 3 {
+		t.Fatalf("expected 3 glyphs but got %d", len(glyphs))
+	}
+	for i, numContours := range []int{2, 1, 2} {
+		if len(glyphs[i].Outlines) != numContours {
+			t.Errorf("glyph %d: expected %d contours but got %d", i, numContours, len(glyphs[i].Outlines))
+		}
+	}
+	// The ascent of the font spans Size.
+	if max := glyphs[0].Max.Y; math.Abs(max-10) > 1e-8 {
+		t.Errorf("expected zero to be 10 units tall but got %f", max)
+	}
+
+	// Contours are wound like TrueType glyphs, with holes reversed.
+	ttZero, err := TextGlyphs(parseTestFont(t), "0", Options{Size: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := contourWindings(ttZero[0].Outlines)
+	if actual := contourWindings(glyphs[0].Outlines); actual != expected {
+		t.Errorf("expected windings %v but got %v", expected, actual)
+	}
+
+	// Outlines are found for glyphs which also have color data.
+	colored, err := ParseTTF(addTestTables(fontBytes,
+		sfntTable{"COLR", buildTestCOLR(1, 2, 4)},
+		sfntTable{"CPAL", buildTestCPAL([][]color.NRGBA{{{R: 255, A: 255}}})},
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	outlines, err := TextOutlines(colored, "01", Options{Size: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(outlines) != 3 {
+		t.Errorf("expected 3 contours for color glyphs but got %d", len(outlines))
+	}
+}
+
+// contourWindings reports whether the largest contour of a glyph is
+// counter-clockwise and whether the others are.
+func contourWindings(contours []Contour) [2]bool {
+	var res [2]bool
+	largest := 0.0
+	for _, c := range contours {
+		var area float64
+		for i := 0; i+1 < len(c); i++ {
+			area += c[i].X*c[i+1].Y - c[i+1].X*c[i].Y
+		}
+		if math.Abs(area) > math.Abs(largest) {
+			if largest != 0 {
+				res[1] = largest > 0
+			}
+			largest = area
+		} else {
+			res[1] = area > 0
+		}
+	}
+	res[0] = largest > 0
+	return res
+}
@@ -0,0 +1,100 @@
+package textcurve
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	gotextfont "github.com/go-text/typesetting/font"
+	ot "github.com/go-text/typesetting/font/opentype"
+	"github.com/golang/freetype/truetype"
+)
+
+// FaceInfo describes one face of a font file or collection.
+type FaceInfo struct {
+	// Index identifies the face for ParseTTC.
+	Index int
+
+	Family    string
+	Subfamily string
+}
+
+// ListFaces enumerates the faces of a TrueType/OpenType collection
+// (.ttc/.otc).
+//
+// A single font file is reported as a collection with one face.
+// WOFF and WOFF2 containers are decoded transparently.
+func ListFaces(data []byte) ([]FaceInfo, error) {
+	data, err := decodeWebFont(data)
+	if err != nil {
+		return nil, err
+	}
+	offsets, err := sfntFaceOffsets(data)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]FaceInfo, len(offsets))
+	for i, off := range offsets {
+		res[i].Index = i
+		table, ok := findSFNTTable(data, off, "name")
+		if !ok {
+			continue
+		}
+		names := parseNameTable(table)
+		res[i].Family = firstNonEmpty(names[nameIDTypographicFamily], names[nameIDFamily])
+		res[i].Subfamily = firstNonEmpty(names[nameIDTypographicSubfamily], names[nameIDSubfamily])
+	}
+	return res, nil
+}
+
+// ParseTTC parses the face at the given index of a font collection
+// (.ttc/.otc).
+//
+// Single font files are accepted as well, in which case index must be 0.
+// WOFF and WOFF2 containers are decoded transparently.
+func ParseTTC(data []byte, index int) (*ParsedFont, error) {
+	data, err := decodeWebFont(data)
+	if err != nil {
+		return nil, err
+	}
+	offsets, err := sfntFaceOffsets(data)
+	if err != nil {
+		return nil, err
+	}
+	if index < 0 || index >= len(offsets) {
+		return nil, fmt.Errorf("face index %d out of range [0, %d)", index, len(offsets))
+	}
+
+	var hbFace *gotextfont.Face
+	hbErr := errors.New("missing face")
+	if loaders, err := ot.NewLoaders(bytes.NewReader(data)); err != nil {
+		hbErr = err
+	} else if index < len(loaders) {
+		var hbFont *gotextfont.Font
+		if hbFont, hbErr = gotextfont.NewFont(loaders[index]); hbErr == nil {
+			hbFace = gotextfont.NewFace(hbFont)
+		}
+	}
+
+	// golang/freetype always loads the first face of a collection, so we
+	// point the first header entry at the requested face. Table offsets are
+	// absolute, so the rest of the file can be shared as-is.
+	ttfData := data
+	if index > 0 {
+		ttfData = append([]byte{}, data...)
+		binary.BigEndian.PutUint32(ttfData[12:16], uint32(offsets[index]))
+	}
+	ttf, ttfErr := truetype.Parse(ttfData)
+
+	return newParsedFont(data, offsets[index], ttf, ttfErr, hbFace, hbErr)
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,91 @@
+package textcurve
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseTTC(t *testing.T) {
+	fontBytes, err := os.ReadFile(filepath.Join("test_data", "LiberationSans-Regular.ttf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	collection := buildTestCollection(fontBytes, 2)
+
+	faces, err := ListFaces(collection)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(faces) != 2 {
+		t.Fatalf("expected 2 faces, got %d", len(faces))
+	}
+	for i, face := range faces {
+		if face.Index != i || face.Family != "Liberation Sans" || face.Subfamily != "Regular" {
+			t.Errorf("unexpected face info: %+v", face)
+		}
+	}
+
+	expectedFont := parseTestFont(t)
+	opt := Options{Size: 10, CurveSegs: 4, Kerning: true}
+	expected, err := TextOutlines(expectedFont, "Hi!", opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range faces {
+		font, err := ParseTTC(collection, i)
+		if err != nil {
+			t.Fatalf("face %d: %v", i, err)
+		}
+		if font.ascent != expectedFont.ascent {
+			t.Errorf("face %d: expected ascent %f, got %f", i, expectedFont.ascent, font.ascent)
+		}
+		if font.hbFace == nil {
+			t.Errorf("face %d: missing shaping face", i)
+		}
+		actual, err := TextOutlines(font, "Hi!", opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		min1, max1 := outlinesBounds(expected)
+		min2, max2 := outlinesBounds(actual)
+		if len(actual) != len(expected) || min1 != min2 || max1 != max2 {
+			t.Errorf("face %d: outlines differ from single font", i)
+		}
+	}
+
+	if _, err := ParseTTC(collection, 2); err == nil {
+		t.Error("expected error for out of range face")
+	}
+}
+
+// buildTestCollection wraps a single font into a collection with n faces,
+// all of which share the same tables.
+func buildTestCollection(font []byte, n int) []byte {
+	numTables := int(binary.BigEndian.Uint16(font[4:6]))
+	dirSize := 12 + 16*numTables
+	headerSize := 12 + 4*n
+
+	fontStart := headerSize + dirSize*(n-1)
+	res := make([]byte, fontStart, fontStart+len(font))
+	copy(res, "ttcf")
+	binary.BigEndian.PutUint32(res[4:], 0x00010000)
+	binary.BigEndian.PutUint32(res[8:], uint32(n))
+	res = append(res, font...)
+
+	for i := 0; i < n; i++ {
+		dirOffset := fontStart
+		if i > 0 {
+			dirOffset = headerSize + dirSize*(i-1)
+			copy(res[dirOffset:], font[:dirSize])
+		}
+		binary.BigEndian.PutUint32(res[12+4*i:], uint32(dirOffset))
+		for j := 0; j < numTables; j++ {
+			rec := res[dirOffset+12+16*j:]
+			offset := binary.BigEndian.Uint32(rec[8:])
+			binary.BigEndian.PutUint32(rec[8:], offset+uint32(fontStart))
+		}
+	}
+	return res
+}
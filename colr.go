@@ -0,0 +1,522 @@
+package textcurve
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image/color"
+	"math"
+	"sort"
+
+	"github.com/golang/freetype/truetype"
+	"github.com/unixpickle/model3d/model2d"
+)
+
+// ColorLayer is a set of outlines filled with a single color.
+type ColorLayer struct {
+	Outlines Outlines
+	Color    color.NRGBA
+
+	// Foreground is set for layers drawn in the text color rather than a
+	// palette color. Color is opaque black for these layers, with the
+	// alpha of the layer applied.
+	Foreground bool
+}
+
+// TextColorLayers is like TextOutlines, but keeps the colors of COLR/CPAL
+// color glyphs, using the CPAL palette selected by Options.Palette.
+//
+// Layers are returned in paint order, from bottom to top, and adjacent
+// layers of the same color are merged. Glyphs without color data produce a
+// Foreground layer.
+//
+// COLR version 1 paint graphs are reduced to solid layers: transforms are
+// applied to the outlines, composites are painted source-over, and
+// gradients are drawn with the average color of their stops.
+//
+// The font is a *ParsedFont, or a *FontSet for glyph fallback.
+func TextColorLayers(font FontSource, s string, opt Options) ([]ColorLayer, error) {
+	fonts, err := sourceFonts(font)
+	if err != nil {
+		return nil, err
+	}
+	return textColorLayers(fonts, s, opt)
+}
+
+// Palettes returns the colors of each CPAL palette, or nil if the font has
+// no color palettes.
+func (p *ParsedFont) Palettes() [][]color.NRGBA {
+	res := make([][]color.NRGBA, len(p.palettes))
+	for i, palette := range p.palettes {
+		res[i] = append([]color.NRGBA{}, palette...)
+	}
+	return res
+}
+
+func textColorLayers(fonts []*ParsedFont, s string, opt Options) ([]ColorLayer, error) {
+	layout, err := layoutText(fonts, s, opt)
+	if err != nil {
+		return nil, err
+	}
+	opt = layout.opt
+
+	var layers []ColorLayer
+	var items []lineContours
+	addLayer := func(line int, layer ColorLayer) {
+		if len(layer.Outlines) == 0 {
+			return
+		}
+		items = append(items, lineContours{line: line, contours: layer.Outlines})
+		if n := len(layers); n > 0 && layers[n-1].Color == layer.Color &&
+			layers[n-1].Foreground == layer.Foreground {
+			layers[n-1].Outlines = append(layers[n-1].Outlines, layer.Outlines...)
+		} else {
+			layers = append(layers, layer)
+		}
+	}
+	for i, line := range layout.lines {
+		for _, g := range line.glyphs {
+			if g.font.colr != nil && g.font.colr.hasGlyph(g.index) {
+				palette, err := g.font.palette(opt.Palette)
+				if err != nil {
+					return nil, err
+				}
+				painter := &colrPainter{font: g.font, palette: palette, glyph: g, segs: opt.CurveSegs}
+				if painter.paintBaseGlyph(g.index, identityAffine, 0) {
+					for _, layer := range painter.layers {
+						layer.Outlines = synthesizeStyle(layer.Outlines, opt)
+						addLayer(i, layer)
+					}
+					continue
+				}
+			}
+			contours, ok := g.contours(opt.CurveSegs)
+			if ok {
+				addLayer(i, ColorLayer{
+					Outlines:   synthesizeStyle(contours, opt),
+					Color:      color.NRGBA{A: 0xff},
+					Foreground: true,
+				})
+			}
+		}
+	}
+
+	if len(layers) == 0 {
+		return nil, nil
+	}
+	layout.align(items)
+
+	return layers, nil
+}
+
+func (p *ParsedFont) palette(idx int) ([]color.NRGBA, error) {
+	if len(p.palettes) == 0 {
+		return nil, nil
+	}
+	if idx < 0 || idx >= len(p.palettes) {
+		return nil, fmt.Errorf("palette %d out of range [0, %d)", idx, len(p.palettes))
+	}
+	return p.palettes[idx], nil
+}
+
+// parseCPAL reads the palettes of a 'CPAL' table.
+func parseCPAL(table []byte) [][]color.NRGBA {
+	if len(table) < 12 {
+		return nil
+	}
+	numEntries := int(binary.BigEndian.Uint16(table[2:4]))
+	numPalettes := int(binary.BigEndian.Uint16(table[4:6]))
+	numRecords := int(binary.BigEndian.Uint16(table[6:8]))
+	recordsOffset := int(binary.BigEndian.Uint32(table[8:12]))
+	if len(table) < 12+2*numPalettes || recordsOffset+4*numRecords > len(table) {
+		return nil
+	}
+	res := make([][]color.NRGBA, numPalettes)
+	for i := range res {
+		first := int(binary.BigEndian.Uint16(table[12+2*i:]))
+		if first+numEntries > numRecords {
+			return nil
+		}
+		res[i] = make([]color.NRGBA, numEntries)
+		for j := range res[i] {
+			rec := table[recordsOffset+4*(first+j):]
+			res[i][j] = color.NRGBA{B: rec[0], G: rec[1], R: rec[2], A: rec[3]}
+		}
+	}
+	return res
+}
+
+// colrTable is a parsed 'COLR' table.
+//
+// Version 0 layer records and version 1 paint tables are both supported;
+// variable (Var*) paints are read at their default values.
+type colrTable struct {
+	data []byte
+
+	baseGlyphs   []byte // version 0 base glyph records
+	layerRecords []byte // version 0 layer records
+
+	baseGlyphList int // offset of the version 1 BaseGlyphList, or 0
+	layerList     int // offset of the version 1 LayerList, or 0
+}
+
+func parseCOLR(table []byte) (*colrTable, error) {
+	if len(table) < 14 {
+		return nil, errors.New("COLR table is too short")
+	}
+	version := binary.BigEndian.Uint16(table[0:2])
+	numBase := int(binary.BigEndian.Uint16(table[2:4]))
+	baseOffset := int(binary.BigEndian.Uint32(table[4:8]))
+	layerOffset := int(binary.BigEndian.Uint32(table[8:12]))
+	numLayers := int(binary.BigEndian.Uint16(table[12:14]))
+	if baseOffset+numBase*6 > len(table) || layerOffset+numLayers*4 > len(table) {
+		return nil, errors.New("COLR records out of bounds")
+	}
+	res := &colrTable{
+		data:         table,
+		baseGlyphs:   table[baseOffset : baseOffset+numBase*6],
+		layerRecords: table[layerOffset : layerOffset+numLayers*4],
+	}
+	if version >= 1 {
+		if len(table) < 22 {
+			return nil, errors.New("COLR table is too short")
+		}
+		res.baseGlyphList = int(binary.BigEndian.Uint32(table[14:18]))
+		res.layerList = int(binary.BigEndian.Uint32(table[18:22]))
+		if res.baseGlyphList+4 > len(table) || res.layerList+4 > len(table) {
+			return nil, errors.New("COLR lists out of bounds")
+		}
+	}
+	return res, nil
+}
+
+func (c *colrTable) hasGlyph(gid truetype.Index) bool {
+	if _, ok := c.basePaint(gid); ok {
+		return true
+	}
+	_, _, ok := c.baseLayers(gid)
+	return ok
+}
+
+// baseLayers finds the version 0 layer range of a glyph.
+func (c *colrTable) baseLayers(gid truetype.Index) (first, count int, ok bool) {
+	n := len(c.baseGlyphs) / 6
+	i := sort.Search(n, func(i int) bool {
+		return binary.BigEndian.Uint16(c.baseGlyphs[i*6:]) >= uint16(gid)
+	})
+	if i == n || binary.BigEndian.Uint16(c.baseGlyphs[i*6:]) != uint16(gid) {
+		return 0, 0, false
+	}
+	rec := c.baseGlyphs[i*6:]
+	first = int(binary.BigEndian.Uint16(rec[2:4]))
+	count = int(binary.BigEndian.Uint16(rec[4:6]))
+	if (first+count)*4 > len(c.layerRecords) {
+		return 0, 0, false
+	}
+	return first, count, true
+}
+
+// basePaint finds the offset of a glyph's version 1 root paint.
+func (c *colrTable) basePaint(gid truetype.Index) (int, bool) {
+	if c.baseGlyphList == 0 {
+		return 0, false
+	}
+	list := c.data[c.baseGlyphList:]
+	n := int(binary.BigEndian.Uint32(list))
+	if 4+n*6 > len(list) {
+		return 0, false
+	}
+	i := sort.Search(n, func(i int) bool {
+		return binary.BigEndian.Uint16(list[4+i*6:]) >= uint16(gid)
+	})
+	if i == n || binary.BigEndian.Uint16(list[4+i*6:]) != uint16(gid) {
+		return 0, false
+	}
+	return c.baseGlyphList + int(binary.BigEndian.Uint32(list[4+i*6+2:])), true
+}
+
+// layerPaint returns the offset of a paint in the version 1 LayerList.
+func (c *colrTable) layerPaint(idx int) (int, bool) {
+	if c.layerList == 0 {
+		return 0, false
+	}
+	list := c.data[c.layerList:]
+	n := int(binary.BigEndian.Uint32(list))
+	if idx >= n || 4+(idx+1)*4 > len(list) {
+		return 0, false
+	}
+	return c.layerList + int(binary.BigEndian.Uint32(list[4+idx*4:])), true
+}
+
+// affine is a 2x3 transform in font units.
+type affine struct {
+	xx, yx, xy, yy, dx, dy float64
+}
+
+var identityAffine = affine{xx: 1, yy: 1}
+
+// mul returns the transform applying b, then a.
+func (a affine) mul(b affine) affine {
+	return affine{
+		xx: a.xx*b.xx + a.xy*b.yx,
+		yx: a.yx*b.xx + a.yy*b.yx,
+		xy: a.xx*b.xy + a.xy*b.yy,
+		yy: a.yx*b.xy + a.yy*b.yy,
+		dx: a.xx*b.dx + a.xy*b.dy + a.dx,
+		dy: a.yx*b.dx + a.yy*b.dy + a.dy,
+	}
+}
+
+func (a affine) apply(c model2d.Coord) model2d.Coord {
+	return model2d.XY(a.xx*c.X+a.xy*c.Y+a.dx, a.yx*c.X+a.yy*c.Y+a.dy)
+}
+
+// aroundCenter conjugates a transform so it is applied around (cx, cy).
+func (a affine) aroundCenter(cx, cy float64) affine {
+	return affine{xx: 1, yy: 1, dx: cx, dy: cy}.mul(a).mul(affine{xx: 1, yy: 1, dx: -cx, dy: -cy})
+}
+
+// colrPaintDepthLimit bounds the recursion of paint graphs, which may
+// otherwise contain cycles in malformed fonts.
+const colrPaintDepthLimit = 64
+
+// colrPainter flattens the color layers of one placed glyph.
+type colrPainter struct {
+	font    *ParsedFont
+	palette []color.NRGBA
+	glyph   placedGlyph
+	segs    int
+
+	layers []ColorLayer
+}
+
+func (c *colrPainter) paintBaseGlyph(gid truetype.Index, transform affine, depth int) bool {
+	colr := c.font.colr
+	if offset, ok := colr.basePaint(gid); ok {
+		c.paint(offset, transform, depth)
+		return true
+	}
+	first, count, ok := colr.baseLayers(gid)
+	if !ok {
+		return false
+	}
+	for i := first; i < first+count; i++ {
+		rec := colr.layerRecords[i*4:]
+		layerGlyph := truetype.Index(binary.BigEndian.Uint16(rec[0:2]))
+		col, fg := c.paletteColor(binary.BigEndian.Uint16(rec[2:4]), 1)
+		c.addGlyph(layerGlyph, transform, col, fg)
+	}
+	return true
+}
+
+// paint flattens the paint table at offset into layers.
+func (c *colrPainter) paint(offset int, transform affine, depth int) {
+	data := c.font.colr.data
+	if depth > colrPaintDepthLimit || offset <= 0 || offset >= len(data) {
+		return
+	}
+	p := data[offset:]
+	format := p[0]
+	if !colrPaintFits(p, format) {
+		return
+	}
+	child := func() int {
+		return offset + int(u24(p[1:4]))
+	}
+	f2dot14 := func(off int) float64 {
+		return float64(int16(binary.BigEndian.Uint16(p[off:]))) / 16384
+	}
+	fword := func(off int) float64 {
+		return float64(int16(binary.BigEndian.Uint16(p[off:])))
+	}
+
+	switch format {
+	case 1: // PaintColrLayers
+		numLayers := int(p[1])
+		first := int(binary.BigEndian.Uint32(p[2:6]))
+		for i := first; i < first+numLayers; i++ {
+			if layer, ok := c.font.colr.layerPaint(i); ok {
+				c.paint(layer, transform, depth+1)
+			}
+		}
+	case 10: // PaintGlyph
+		gid := truetype.Index(binary.BigEndian.Uint16(p[4:6]))
+		col, fg := c.fillColor(child(), depth+1)
+		c.addGlyph(gid, transform, col, fg)
+	case 11: // PaintColrGlyph
+		c.paintBaseGlyph(truetype.Index(binary.BigEndian.Uint16(p[1:3])), transform, depth+1)
+	case 12, 13: // PaintTransform, PaintVarTransform
+		t := offset + int(u24(p[4:7]))
+		if t+24 > len(data) {
+			return
+		}
+		m := data[t:]
+		fixed := func(off int) float64 {
+			return float64(int32(binary.BigEndian.Uint32(m[off:]))) / 65536
+		}
+		local := affine{xx: fixed(0), yx: fixed(4), xy: fixed(8), yy: fixed(12), dx: fixed(16), dy: fixed(20)}
+		c.paint(child(), transform.mul(local), depth+1)
+	case 14, 15: // PaintTranslate
+		local := affine{xx: 1, yy: 1, dx: fword(4), dy: fword(6)}
+		c.paint(child(), transform.mul(local), depth+1)
+	case 16, 17, 18, 19: // PaintScale, PaintScaleAroundCenter
+		local := affine{xx: f2dot14(4), yy: f2dot14(6)}
+		if format >= 18 {
+			local = local.aroundCenter(fword(8), fword(10))
+		}
+		c.paint(child(), transform.mul(local), depth+1)
+	case 20, 21, 22, 23: // PaintScaleUniform, PaintScaleUniformAroundCenter
+		s := f2dot14(4)
+		local := affine{xx: s, yy: s}
+		if format >= 22 {
+			local = local.aroundCenter(fword(6), fword(8))
+		}
+		c.paint(child(), transform.mul(local), depth+1)
+	case 24, 25, 26, 27: // PaintRotate, PaintRotateAroundCenter
+		angle := f2dot14(4) * math.Pi
+		sin, cos := math.Sincos(angle)
+		local := affine{xx: cos, yx: sin, xy: -sin, yy: cos}
+		if format >= 26 {
+			local = local.aroundCenter(fword(6), fword(8))
+		}
+		c.paint(child(), transform.mul(local), depth+1)
+	case 28, 29, 30, 31: // PaintSkew, PaintSkewAroundCenter
+		local := affine{
+			xx: 1,
+			yx: math.Tan(f2dot14(6) * math.Pi),
+			xy: -math.Tan(f2dot14(4) * math.Pi),
+			yy: 1,
+		}
+		if format >= 30 {
+			local = local.aroundCenter(fword(8), fword(10))
+		}
+		c.paint(child(), transform.mul(local), depth+1)
+	case 32: // PaintComposite
+		c.paint(offset+int(u24(p[5:8])), transform, depth+1)
+		c.paint(child(), transform, depth+1)
+	default:
+		// Fills outside of a PaintGlyph have no outline to fill.
+	}
+}
+
+// fillColor reduces the paint used to fill a glyph to a single color.
+func (c *colrPainter) fillColor(offset int, depth int) (color.NRGBA, bool) {
+	data := c.font.colr.data
+	black := color.NRGBA{A: 0xff}
+	if depth > colrPaintDepthLimit || offset <= 0 || offset >= len(data) {
+		return black, true
+	}
+	p := data[offset:]
+	format := p[0]
+	if !colrPaintFits(p, format) {
+		return black, true
+	}
+	switch format {
+	case 2, 3: // PaintSolid
+		alpha := float64(int16(binary.BigEndian.Uint16(p[3:5]))) / 16384
+		return c.paletteColor(binary.BigEndian.Uint16(p[1:3]), alpha)
+	case 4, 5, 6, 7, 8, 9: // gradients
+		return c.colorLineAverage(offset+int(u24(p[1:4])), format%2 == 1)
+	case 1: // PaintColrLayers
+		if p[1] > 0 {
+			if layer, ok := c.font.colr.layerPaint(int(binary.BigEndian.Uint32(p[2:6]))); ok {
+				return c.fillColor(layer, depth+1)
+			}
+		}
+	case 11:
+		// A color glyph used as a fill has no single color.
+	default:
+		// Transforms and nested clips keep the color of their child.
+		// For composites, the source (drawn on top) wins.
+		return c.fillColor(offset+int(u24(p[1:4])), depth+1)
+	}
+	return black, true
+}
+
+// colorLineAverage averages the colors of a (Var)ColorLine.
+func (c *colrPainter) colorLineAverage(offset int, variable bool) (color.NRGBA, bool) {
+	data := c.font.colr.data
+	stopSize := 6
+	if variable {
+		stopSize = 10
+	}
+	if offset+3 > len(data) {
+		return color.NRGBA{A: 0xff}, true
+	}
+	numStops := int(binary.BigEndian.Uint16(data[offset+1:]))
+	if numStops == 0 || offset+3+numStops*stopSize > len(data) {
+		return color.NRGBA{A: 0xff}, true
+	}
+	var r, g, b, a float64
+	allForeground := true
+	for i := 0; i < numStops; i++ {
+		stop := data[offset+3+i*stopSize:]
+		alpha := float64(int16(binary.BigEndian.Uint16(stop[4:6]))) / 16384
+		col, fg := c.paletteColor(binary.BigEndian.Uint16(stop[2:4]), alpha)
+		allForeground = allForeground && fg
+		r += float64(col.R)
+		g += float64(col.G)
+		b += float64(col.B)
+		a += float64(col.A)
+	}
+	n := float64(numStops)
+	avg := color.NRGBA{
+		R: uint8(math.Round(r / n)),
+		G: uint8(math.Round(g / n)),
+		B: uint8(math.Round(b / n)),
+		A: uint8(math.Round(a / n)),
+	}
+	return avg, allForeground
+}
+
+// paletteColor looks up a palette entry, with 0xFFFF denoting the text
+// foreground color.
+func (c *colrPainter) paletteColor(idx uint16, alpha float64) (color.NRGBA, bool) {
+	col := color.NRGBA{A: 0xff}
+	fg := true
+	if idx != 0xffff && int(idx) < len(c.palette) {
+		col, fg = c.palette[idx], false
+	}
+	col.A = uint8(math.Round(float64(col.A) * math.Max(0, math.Min(1, alpha))))
+	return col, fg
+}
+
+// addGlyph adds the transformed outline of gid as a layer.
+func (c *colrPainter) addGlyph(gid truetype.Index, transform affine, col color.NRGBA, fg bool) {
+	contours, ok := c.font.glyphContours(gid, 0, 1, c.segs)
+	if !ok || len(contours) == 0 {
+		return
+	}
+	mirrored := transform.xx*transform.yy-transform.xy*transform.yx < 0
+	g := c.glyph
+	for _, contour := range contours {
+		for i, p := range contour {
+			contour[i] = g.toModel(transform.apply(p))
+		}
+		if mirrored {
+			for i, j := 0, len(contour)-1; i < j; i, j = i+1, j-1 {
+				contour[i], contour[j] = contour[j], contour[i]
+			}
+		}
+	}
+	c.layers = append(c.layers, ColorLayer{Outlines: contours, Color: col, Foreground: fg})
+}
+
+// colrPaintFits checks that the fixed-size fields of a paint table are in
+// bounds.
+func colrPaintFits(p []byte, format uint8) bool {
+	return int(format) < len(colrPaintSizes) && colrPaintSizes[format] > 0 &&
+		len(p) >= colrPaintSizes[format]
+}
+
+// colrPaintSizes maps paint formats to the size of their fixed fields.
+var colrPaintSizes = [...]int{
+	1: 6, 2: 5, 3: 9, 4: 16, 5: 20, 6: 16, 7: 20, 8: 12, 9: 16,
+	10: 6, 11: 3, 12: 7, 13: 7, 14: 8, 15: 12, 16: 8, 17: 12,
+	18: 12, 19: 16, 20: 6, 21: 10, 22: 10, 23: 14, 24: 6, 25: 10,
+	26: 10, 27: 14, 28: 8, 29: 12, 30: 12, 31: 16, 32: 8,
+}
+
+func u24(b []byte) uint32 {
+	return uint32(b[0])<<16 | uint32(b[1])<<8 | uint32(b[2])
+}
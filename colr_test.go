@@ -0,0 +1,169 @@
+package textcurve
+
+import (
+	"encoding/binary"
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTextColorLayers(t *testing.T) {
+	plain := parseTestFont(t)
+	gidA := uint16(plain.TTFont.Index('A'))
+	gidB := uint16(plain.TTFont.Index('B'))
+	gido := uint16(plain.TTFont.Index('o'))
+
+	fontBytes, err := os.ReadFile(filepath.Join("test_data", "LiberationSans-Regular.ttf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	colr := buildTestCOLR(gidA, gidB, gido)
+	cpal := buildTestCPAL([][]color.NRGBA{
+		{{R: 255, A: 255}, {B: 255, A: 255}},
+		{{G: 255, A: 255}, {R: 255, G: 255, B: 255, A: 128}},
+	})
+	font, err := ParseTTF(addTestTables(fontBytes, sfntTable{"COLR", colr}, sfntTable{"CPAL", cpal}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(font.Palettes()); n != 2 {
+		t.Fatalf("expected 2 palettes but got %d", n)
+	}
+
+	opt := Options{Size: 10, Palette: 1}
+	layers, err := TextColorLayers(font, "AB", opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(layers) != 3 {
+		t.Fatalf("expected 3 layers but got %d", len(layers))
+	}
+	expectedColors := []color.NRGBA{
+		{G: 255, A: 255},
+		{R: 255, G: 255, B: 255, A: 128},
+		{A: 255},
+	}
+	for i, layer := range layers {
+		if layer.Color != expectedColors[i] {
+			t.Errorf("layer %d: expected color %v but got %v", i, expectedColors[i], layer.Color)
+		}
+		if layer.Foreground != (i == 2) {
+			t.Errorf("layer %d: unexpected foreground flag", i)
+		}
+	}
+
+	// The version 0 layers reuse the outlines of other glyphs.
+	expected, err := TextOutlines(plain, "o", opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertSameBounds(t, "layer 0", layers[0].Outlines, expected, 0)
+
+	// The version 1 paint translates B by 100 font units.
+	outlinesA, err := TextOutlines(plain, "A", opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	outlinesAB, err := TextOutlines(plain, "AB", opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scale := 10 / plain.Metrics().TypoAscender
+	assertSameBounds(t, "layer 2", layers[2].Outlines, outlinesAB[len(outlinesA):], 100*scale)
+
+	// Font sets keep the colors of their fonts.
+	setLayers, err := TextColorLayers(NewFontSet(font, plain), "AB", opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(setLayers) != len(layers) {
+		t.Errorf("expected %d layers from font set but got %d", len(layers), len(setLayers))
+	}
+
+	if _, err := TextColorLayers(font, "AB", Options{Size: 10, Palette: 2}); err == nil {
+		t.Error("expected error for out of range palette")
+	}
+
+	// Fonts without color data produce a single foreground layer.
+	layers, err = TextColorLayers(plain, "AB", opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(layers) != 1 || !layers[0].Foreground || len(layers[0].Outlines) != len(outlinesAB) {
+		t.Errorf("unexpected plain layers: %d", len(layers))
+	}
+}
+
+// buildTestCOLR creates a version 1 COLR table where glyph a has two
+// version 0 layers (o, then a itself), and glyph b is painted in the
+// foreground color, translated by 100 units.
+func buildTestCOLR(a, b, o uint16) []byte {
+	const headerSize = 34
+	baseRecords := headerSize
+	layerRecords := baseRecords + 6
+	baseGlyphList := layerRecords + 8
+
+	res := make([]byte, baseGlyphList)
+	binary.BigEndian.PutUint16(res[0:], 1)
+	binary.BigEndian.PutUint16(res[2:], 1)
+	binary.BigEndian.PutUint32(res[4:], uint32(baseRecords))
+	binary.BigEndian.PutUint32(res[8:], uint32(layerRecords))
+	binary.BigEndian.PutUint16(res[12:], 2)
+	binary.BigEndian.PutUint32(res[14:], uint32(baseGlyphList))
+
+	binary.BigEndian.PutUint16(res[baseRecords:], a)
+	binary.BigEndian.PutUint16(res[baseRecords+4:], 2)
+	binary.BigEndian.PutUint16(res[layerRecords:], o)
+	binary.BigEndian.PutUint16(res[layerRecords+4:], a)
+	binary.BigEndian.PutUint16(res[layerRecords+6:], 1)
+
+	// BaseGlyphList with one record, followed by its paints.
+	list := []byte{0, 0, 0, 1, byte(b >> 8), byte(b), 0, 0, 0, 10}
+	list = append(list, 14, 0, 0, 8, 0, 100, 0, 0)        // PaintTranslate
+	list = append(list, 10, 0, 0, 6, byte(b>>8), byte(b)) // PaintGlyph
+	list = append(list, 2, 0xff, 0xff, 0x40, 0)           // PaintSolid
+	return append(res, list...)
+}
+
+func buildTestCPAL(palettes [][]color.NRGBA) []byte {
+	numEntries := len(palettes[0])
+	recordsOffset := 12 + 2*len(palettes)
+	res := make([]byte, recordsOffset)
+	binary.BigEndian.PutUint16(res[2:], uint16(numEntries))
+	binary.BigEndian.PutUint16(res[4:], uint16(len(palettes)))
+	binary.BigEndian.PutUint16(res[6:], uint16(numEntries*len(palettes)))
+	binary.BigEndian.PutUint32(res[8:], uint32(recordsOffset))
+	for i, palette := range palettes {
+		binary.BigEndian.PutUint16(res[12+2*i:], uint16(i*numEntries))
+		for _, c := range palette {
+			res = append(res, c.B, c.G, c.R, c.A)
+		}
+	}
+	return res
+}
+
+// addTestTables rebuilds a single font file with extra tables, which
+// replace existing tables with the same tags.
+func addTestTables(font []byte, extra ...sfntTable) []byte {
+	numTables := int(binary.BigEndian.Uint16(font[4:6]))
+	var tables []sfntTable
+	for i := 0; i < numTables; i++ {
+		rec := font[12+16*i:]
+		offset := binary.BigEndian.Uint32(rec[8:])
+		length := binary.BigEndian.Uint32(rec[12:])
+		table := sfntTable{tag: string(rec[:4]), data: font[offset : offset+length]}
+		for _, e := range extra {
+			if e.tag == table.tag {
+				// Replace the existing table.
+				table.data = nil
+			}
+		}
+		if table.data != nil {
+			tables = append(tables, table)
+		}
+	}
+	tables = append(tables, extra...)
+	face := sfntFace{flavor: binary.BigEndian.Uint32(font), tables: allTableIndices(len(tables))}
+	return writeSFNT([]sfntFace{face}, tables, false)
+}
@@ -0,0 +1,136 @@
+package textcurve
+
+import (
+	"errors"
+	"unicode"
+)
+
+// FontSource is the font which text is drawn with: either a single
+// *ParsedFont, or a *FontSet which falls back to other fonts for runes the
+// primary font lacks.
+type FontSource interface {
+	fontList() ([]*ParsedFont, error)
+}
+
+// FontSet is an ordered list of fonts used for glyph fallback.
+//
+// Each rune is drawn with the first font in the set that has a glyph for
+// it. The string is split into runs of consecutive runes using the same
+// font, and each run is shaped with its own font before being placed on a
+// shared baseline.
+//
+// The first font is the primary font. It determines the scale used for
+// Options.Size, and fallback fonts are drawn at the same em size.
+type FontSet struct {
+	Fonts []*ParsedFont
+}
+
+// NewFontSet creates a FontSet from a primary font and its fallbacks.
+func NewFontSet(primary *ParsedFont, fallbacks ...*ParsedFont) *FontSet {
+	return &FontSet{Fonts: append([]*ParsedFont{primary}, fallbacks...)}
+}
+
+func (fs *FontSet) fontList() ([]*ParsedFont, error) {
+	if fs == nil || len(fs.Fonts) == 0 {
+		return nil, errors.New("empty font set")
+	}
+	return fs.Fonts, nil
+}
+
+func (p *ParsedFont) fontList() ([]*ParsedFont, error) {
+	if p == nil {
+		return nil, errors.New("nil font")
+	}
+	return []*ParsedFont{p}, nil
+}
+
+// sourceFonts returns the fonts of a source, starting with the primary one.
+func sourceFonts(src FontSource) ([]*ParsedFont, error) {
+	if src == nil {
+		return nil, errors.New("nil font")
+	}
+	return src.fontList()
+}
+
+type fontRun struct {
+	font       int // index into the font list
+	start, end int // rune range
+}
+
+// itemizeFonts splits runes into runs which use the same font.
+//
+// Each rune uses the first font which covers it. Marks, joiners and spaces
+// stay in the current run when its font covers them, so that clusters are
+// shaped together. Runes which no font covers stay in the current run as
+// well, and are drawn as that font's .notdef glyph.
+func itemizeFonts(fonts []*ParsedFont, runes []rune) []fontRun {
+	if len(fonts) == 1 {
+		return []fontRun{{font: 0, start: 0, end: len(runes)}}
+	}
+	var res []fontRun
+	for i, r := range runes {
+		font := -1
+		if len(res) > 0 {
+			cur := res[len(res)-1].font
+			if fonts[cur].hasRune(r) && sticksToRun(r) {
+				font = cur
+			}
+		}
+		for j := 0; font < 0 && j < len(fonts); j++ {
+			if fonts[j].hasRune(r) {
+				font = j
+			}
+		}
+		if font < 0 {
+			font = 0
+			if len(res) > 0 {
+				font = res[len(res)-1].font
+			}
+		}
+		if len(res) > 0 && res[len(res)-1].font == font {
+			res[len(res)-1].end = i + 1
+		} else {
+			res = append(res, fontRun{font: font, start: i, end: i + 1})
+		}
+	}
+	return res
+}
+
+// sticksToRun reports whether a rune should be kept in the current run
+// rather than switching to a font listed earlier in the set.
+func sticksToRun(r rune) bool {
+	return unicode.In(r, unicode.Mn, unicode.Mc, unicode.Me, unicode.Variation_Selector) ||
+		r == '\u200c' || r == '\u200d' || unicode.IsSpace(r)
+}
+
+// hasRune reports whether the font maps r to a glyph.
+func (p *ParsedFont) hasRune(r rune) bool {
+	if p.hbFace != nil {
+		_, ok := p.hbFace.NominalGlyph(r)
+		return ok
+	}
+	return p.TTFont.Index(r) != 0
+}
+
+// fontInstances applies per-call settings to every font in a fallback list.
+// Fallback fonts only receive the variation axes they support.
+func fontInstances(fonts []*ParsedFont, opt Options) ([]*ParsedFont, error) {
+	res := make([]*ParsedFont, len(fonts))
+	for i, f := range fonts {
+		fontOpt := opt
+		if i > 0 && len(opt.Variations) > 0 {
+			fontOpt.Variations = map[string]float64{}
+			for tag, value := range opt.Variations {
+				if f.hasAxis(tag) {
+					fontOpt.Variations[tag] = value
+				}
+			}
+		}
+		inst, err := f.instance(fontOpt)
+		if err != nil {
+			return nil, err
+		}
+		res[i] = inst
+	}
+	return res, nil
+}
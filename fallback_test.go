@@ -0,0 +1,192 @@
+package textcurve
+
+import (
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func TestTextOutlinesFallback(t *testing.T) {
+	font := parseTestFont(t)
+	opt := Options{Size: 10, CurveSegs: 4, Kerning: true}
+
+	expected, err := TextOutlines(font, "Hi, Ωμέγα!", opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual, err := TextOutlines(NewFontSet(font, font), "Hi, Ωμέγα!", opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(actual) != len(expected) {
+		t.Fatalf("expected %d contours, got %d", len(expected), len(actual))
+	}
+	for i := range actual {
+		for j := range actual[i] {
+			if actual[i][j].Dist(expected[i][j]) > 1e-8 {
+				t.Fatalf("contour %d differs", i)
+			}
+		}
+	}
+
+	if _, err := TextOutlines(&FontSet{}, "Hi", opt); err == nil {
+		t.Fatal("expected error for empty font set")
+	}
+	var nilFont *ParsedFont
+	for _, font := range []FontSource{nil, nilFont, (*FontSet)(nil)} {
+		if _, err := TextOutlines(font, "Hi", opt); err == nil {
+			t.Fatalf("expected error for %#v", font)
+		}
+	}
+}
+
+func TestItemizeFonts(t *testing.T) {
+	font := parseTestFont(t)
+	ttOnly := &ParsedFont{TTFont: font.TTFont}
+
+	// The second font is only used for runes the first one lacks, and
+	// uncovered runes stay in the current run.
+	runes := []rune("Hi é 漢x")
+	runs := itemizeFonts([]*ParsedFont{font, ttOnly}, runes)
+	if len(runs) != 1 || runs[0] != (fontRun{font: 0, start: 0, end: len(runes)}) {
+		t.Fatalf("unexpected runs: %v", runs)
+	}
+
+	if !font.hasRune('Ω') || font.hasRune('漢') {
+		t.Fatal("unexpected coverage from shaping face")
+	}
+	if !ttOnly.hasRune('Ω') || ttOnly.hasRune('漢') {
+		t.Fatal("unexpected coverage from TrueType cmap")
+	}
+}
+
+func TestTextGlyphsFallbackSubset(t *testing.T) {
+	full := parseTestFont(t)
+	fontBytes, err := os.ReadFile(filepath.Join("test_data", "LiberationSans-Regular.ttf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mapping := map[rune]uint16{' ': uint16(full.TTFont.Index(' '))}
+	for r := 'A'; r <= 'Z'; r++ {
+		mapping[r] = uint16(full.TTFont.Index(r))
+	}
+	primary, err := ParseTTF(addTestTables(fontBytes, sfntTable{"cmap", buildTestCmap(mapping)}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if primary.hasRune('a') || primary.hasRune('0') || !primary.hasRune('Q') {
+		t.Fatal("unexpected coverage of subset font")
+	}
+	cffBytes, err := os.ReadFile(filepath.Join("test_data", "CFFTest.otf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fallback, err := ParseTTF(cffBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fonts := NewFontSet(primary, fallback)
+
+	const text = "HI 01 Q"
+	runs := itemizeFonts(fonts.Fonts, []rune(text))
+	expectedRuns := []fontRun{{font: 0, start: 0, end: 3}, {font: 1, start: 3, end: 5}, {font: 0, start: 5, end: 7}}
+	if len(runs) != len(expectedRuns) {
+		t.Fatalf("unexpected runs: %v", runs)
+	}
+	for i, run := range runs {
+		if run != expectedRuns[i] {
+			t.Fatalf("unexpected runs: %v", runs)
+		}
+	}
+
+	opt := Options{Size: 10, CurveSegs: 4}
+	glyphs, err := TextGlyphs(fonts, text, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(glyphs) != len(text) {
+		t.Fatalf("expected %d glyphs but got %d", len(text), len(glyphs))
+	}
+
+	// Each glyph has the outlines of its own font, and the fallback font is
+	// drawn at the primary font's em size even though the fonts have
+	// different units per em and ascents.
+	emSize := primary.sizeScale(opt.Size) * primary.UnitsPerEm()
+	for i, g := range glyphs {
+		source, scale := full, 1.0
+		if g.Font == 1 {
+			source = fallback
+			scale = emSize / (fallback.sizeScale(opt.Size) * fallback.UnitsPerEm())
+		}
+		if expected := []int{0, 0, 0, 1, 1, 0, 0}[i]; g.Font != expected {
+			t.Fatalf("glyph %d: expected font %d but got %d", i, expected, g.Font)
+		}
+		expected, err := TextGlyphs(source, text[g.Start:g.End], opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected[0].Index != g.Index || math.Abs(expected[0].Advance*scale-g.Advance) > 1e-8 {
+			t.Fatalf("glyph %d: expected %+v but got %+v", i, expected[0], g)
+		}
+		if len(expected[0].Outlines) != len(g.Outlines) {
+			t.Fatalf("glyph %d: expected %d contours but got %d", i, len(expected[0].Outlines),
+				len(g.Outlines))
+		}
+		for j, contour := range g.Outlines {
+			for k, p := range contour {
+				expectedPoint := expected[0].Outlines[j][k].Sub(expected[0].Pen).Scale(scale).Add(g.Pen)
+				if p.Dist(expectedPoint) > 1e-8 {
+					t.Fatalf("glyph %d: contour %d differs", i, j)
+				}
+			}
+		}
+	}
+	if zero := glyphs[3]; math.Abs(zero.Max.Y-emSize*800/1000) > 1e-8 {
+		t.Errorf("unexpected height of fallback glyph: %f", zero.Max.Y)
+	}
+}
+
+// buildTestCmap creates a 'cmap' table with a format 4 subtable.
+func buildTestCmap(mapping map[rune]uint16) []byte {
+	var runes []rune
+	for r := range mapping {
+		runes = append(runes, r)
+	}
+	sort.Slice(runes, func(i, j int) bool {
+		return runes[i] < runes[j]
+	})
+	runes = append(runes, 0xffff)
+
+	segCount := len(runes)
+	subtable := make([]byte, 16+8*segCount)
+	binary.BigEndian.PutUint16(subtable[0:], 4)
+	binary.BigEndian.PutUint16(subtable[2:], uint16(len(subtable)))
+	binary.BigEndian.PutUint16(subtable[6:], uint16(2*segCount))
+	searchRange, entrySelector := 2, 0
+	for searchRange*2 <= 2*segCount {
+		searchRange *= 2
+		entrySelector++
+	}
+	binary.BigEndian.PutUint16(subtable[8:], uint16(searchRange))
+	binary.BigEndian.PutUint16(subtable[10:], uint16(entrySelector))
+	binary.BigEndian.PutUint16(subtable[12:], uint16(2*segCount-searchRange))
+	for i, r := range runes {
+		delta := uint16(1) // maps 0xffff to glyph 0
+		if r != 0xffff {
+			delta = mapping[r] - uint16(r)
+		}
+		binary.BigEndian.PutUint16(subtable[14+2*i:], uint16(r))
+		binary.BigEndian.PutUint16(subtable[16+2*segCount+2*i:], uint16(r))
+		binary.BigEndian.PutUint16(subtable[16+4*segCount+2*i:], delta)
+	}
+
+	table := make([]byte, 12, 12+len(subtable))
+	binary.BigEndian.PutUint16(table[2:], 1)
+	binary.BigEndian.PutUint16(table[4:], 3)
+	binary.BigEndian.PutUint16(table[6:], 1)
+	binary.BigEndian.PutUint32(table[8:], 12)
+	return append(table, subtable...)
+}
@@ -0,0 +1,94 @@
+package textcurve
+
+import (
+	"fmt"
+	"sort"
+
+	ot "github.com/go-text/typesetting/font/opentype"
+	"github.com/go-text/typesetting/shaping"
+)
+
+// Feature sets an OpenType feature while shaping text.
+//
+// For example, {Tag: "tnum", Value: 1} selects tabular numerals, and
+// {Tag: "liga", Value: 0} disables standard ligatures.
+type Feature struct {
+	// Tag is the four character feature tag, such as "smcp" or "ss01".
+	Tag string
+
+	// Value is 0 to disable the feature and 1 to enable it. Features with
+	// several alternates, such as "salt" or "cv01", use larger values to
+	// select one of them.
+	Value uint32
+
+	// Start and End limit the feature to the runes [Start, End) of the
+	// text, counting line breaks. An End of 0 means the end of the text.
+	Start, End int
+}
+
+// checkFeatures validates Options.Features.
+func checkFeatures(features []Feature) error {
+	for _, f := range features {
+		if len(f.Tag) != 4 {
+			return fmt.Errorf("invalid feature tag %q", f.Tag)
+		}
+		for i := 0; i < len(f.Tag); i++ {
+			if f.Tag[i] < 0x20 || f.Tag[i] > 0x7e {
+				return fmt.Errorf("invalid feature tag %q", f.Tag)
+			}
+		}
+		if f.Start < 0 || f.End < 0 || (f.End != 0 && f.End <= f.Start) {
+			return fmt.Errorf("invalid range [%d, %d) for feature %q", f.Start, f.End, f.Tag)
+		}
+	}
+	return nil
+}
+
+// covers reports whether the feature applies to all of the runes [start,
+// end) of the text.
+func (f Feature) covers(start, end int) bool {
+	return f.Start <= start && (f.End == 0 || end <= f.End)
+}
+
+// splitFeatureRuns splits a run at the boundaries of ranged features, and
+// sets the features of each piece. Since HarfBuzz only receives features
+// for whole runs, ranged features break up shaping like font changes do.
+//
+// lineStart is the offset of the run's line in the text.
+func splitFeatureRuns(run textRun, lineStart int, features []Feature) []textRun {
+	if len(features) == 0 {
+		return []textRun{run}
+	}
+	var cuts []int
+	for _, f := range features {
+		for _, b := range []int{f.Start - lineStart, f.End - lineStart} {
+			if b > run.start && b < run.end {
+				cuts = append(cuts, b)
+			}
+		}
+	}
+	sort.Ints(cuts)
+	cuts = append(cuts, run.end)
+
+	var res []textRun
+	start := run.start
+	for _, cut := range cuts {
+		if cut == start {
+			continue
+		}
+		piece := run
+		piece.start, piece.end = start, cut
+		piece.features = nil
+		for _, f := range features {
+			if f.covers(lineStart+start, lineStart+cut) {
+				piece.features = append(piece.features, shaping.FontFeature{
+					Tag:   ot.MustNewTag(f.Tag),
+					Value: f.Value,
+				})
+			}
+		}
+		res = append(res, piece)
+		start = cut
+	}
+	return res
+}
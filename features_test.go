@@ -0,0 +1,93 @@
+package textcurve
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFeatures(t *testing.T) {
+	fontBytes, err := os.ReadFile(filepath.Join("test_data", "LiberationSans-Regular.ttf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Make "ss01" substitute 'a' with 'b'.
+	gsub := buildTestGSUB("ss01", 68, 69)
+	font, err := ParseTTF(addTestTables(fontBytes, sfntTable{"GSUB", gsub}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	indices := func(text string, features []Feature) []int {
+		layout, err := layoutText([]*ParsedFont{font}, text, Options{Size: 10, Features: features})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var res []int
+		for _, line := range layout.lines {
+			for _, g := range line.glyphs {
+				res = append(res, int(g.index))
+			}
+		}
+		return res
+	}
+	cases := []struct {
+		text     string
+		features []Feature
+		expected []int
+	}{
+		{"aaa", nil, []int{68, 68, 68}},
+		{"aaa", []Feature{{Tag: "ss01", Value: 1}}, []int{69, 69, 69}},
+		{"aaa", []Feature{{Tag: "ss01", Value: 1, Start: 1, End: 2}}, []int{68, 69, 68}},
+		{"aaa", []Feature{{Tag: "ss01", Value: 1, Start: 2}}, []int{68, 68, 69}},
+		{"aaa", []Feature{{Tag: "ss01", Value: 1}, {Tag: "ss01", Value: 0, Start: 1, End: 2}},
+			[]int{69, 68, 69}},
+		{"a\na", []Feature{{Tag: "ss01", Value: 1, Start: 2}}, []int{68, 69}},
+	}
+	for _, c := range cases {
+		if actual := indices(c.text, c.features); !reflect.DeepEqual(actual, c.expected) {
+			t.Errorf("%q %v: expected %v but got %v", c.text, c.features, c.expected, actual)
+		}
+	}
+
+	for _, features := range [][]Feature{
+		{{Tag: "ss1", Value: 1}},
+		{{Tag: "ss01\x00", Value: 1}},
+		{{Tag: "ss01", Value: 1, Start: -1}},
+		{{Tag: "ss01", Value: 1, Start: 2, End: 2}},
+	} {
+		if _, err := TextOutlines(font, "aaa", Options{Size: 10, Features: features}); err == nil {
+			t.Errorf("%v: expected an error", features)
+		}
+	}
+}
+
+// buildTestGSUB creates a GSUB table with a single feature, which
+// substitutes one glyph with another in every script.
+func buildTestGSUB(tag string, from, to uint16) []byte {
+	u16 := func(values ...int) []byte {
+		res := make([]byte, 2*len(values))
+		for i, v := range values {
+			binary.BigEndian.PutUint16(res[2*i:], uint16(v))
+		}
+		return res
+	}
+	scriptList := append(u16(1), "DFLT"...)
+	scriptList = append(scriptList, u16(8)...)
+	scriptList = append(scriptList, u16(4, 0)...)            // Script
+	scriptList = append(scriptList, u16(0, 0xffff, 1, 0)...) // LangSys
+
+	featureList := append(u16(1), tag...)
+	featureList = append(featureList, u16(8, 0, 1, 0)...)
+
+	lookupList := u16(1, 4)
+	lookupList = append(lookupList, u16(1, 0, 1, 8)...)                        // Lookup
+	lookupList = append(lookupList, u16(2, 8, 1, int(to), 1, 1, int(from))...) // SingleSubst
+
+	res := u16(1, 0, 10, 10+len(scriptList), 10+len(scriptList)+len(featureList))
+	res = append(res, scriptList...)
+	res = append(res, featureList...)
+	return append(res, lookupList...)
+}
@@ -0,0 +1,155 @@
+package textcurve
+
+import (
+	"errors"
+	"math"
+)
+
+// fitPrecision is the relative precision of the size found by FitText.
+const fitPrecision = 1e-3
+
+// FitOptions describes the box filled by FitText.
+type FitOptions struct {
+	// Width and Height are the size of the box in model units.
+	Width  float64
+	Height float64
+
+	// Padding is kept free on every side of the box.
+	Padding float64
+
+	// Wrap breaks lines to fit the width of the box (or its height for
+	// vertical text), overriding Options.MaxWidth.
+	Wrap bool
+
+	// Advance measures text by its advances and line heights, rather than
+	// by the bounds of its outlines. This keeps the size of text from
+	// depending on which glyphs it contains.
+	Advance bool
+}
+
+// FitText finds the largest Options.Size for which the text fits in a box,
+// and returns the outlines at that size along with the size.
+//
+// Outlines are aligned per Options.Align as in TextOutlines, so placing
+// them in the box is up to the caller. The size is found within a relative
+// precision of 0.1%, reusing the parsed font for every attempt.
+//
+// The font is a *ParsedFont, or a *FontSet for glyph fallback.
+func FitText(font FontSource, s string, opt Options, fit FitOptions) (Outlines, float64, error) {
+	fonts, err := sourceFonts(font)
+	if err != nil {
+		return nil, 0, err
+	}
+	width := fit.Width - 2*fit.Padding
+	height := fit.Height - 2*fit.Padding
+	if width <= 0 || height <= 0 {
+		return nil, 0, errors.New("box is empty after padding")
+	}
+	fits := func(size float64) (bool, error) {
+		trial := opt
+		trial.Size = size
+		if fit.Wrap {
+			trial.MaxWidth = width
+			if opt.Vertical {
+				trial.MaxWidth = height
+			}
+		}
+		w, h, err := measureText(fonts, s, trial, fit.Advance)
+		if err != nil {
+			return false, err
+		}
+		return w <= width && h <= height, nil
+	}
+
+	// Text scales with its size, apart from options in model units like
+	// Embolden, so a measurement at size 1 gives a good first guess.
+	unwrapped := opt
+	unwrapped.Size = 1
+	if fit.Wrap {
+		unwrapped.MaxWidth = 0
+	}
+	w, h, err := measureText(fonts, s, unwrapped, fit.Advance)
+	if err != nil {
+		return nil, 0, err
+	}
+	if w == 0 && h == 0 {
+		return nil, 0, errors.New("text has no extent to fit")
+	}
+	guess := math.Min(width/w, height/h)
+
+	// Bracket the largest size that fits, then bisect.
+	lo, hi := guess, guess
+	ok, err := fits(guess)
+	if err != nil {
+		return nil, 0, err
+	}
+	if ok {
+		for ok && hi < guess*1e6 {
+			lo, hi = hi, hi*2
+			if ok, err = fits(hi); err != nil {
+				return nil, 0, err
+			}
+		}
+	} else {
+		for !ok && lo > guess*1e-6 {
+			hi, lo = lo, lo/2
+			if ok, err = fits(lo); err != nil {
+				return nil, 0, err
+			}
+		}
+		if !ok {
+			return nil, 0, errors.New("text does not fit at any size")
+		}
+	}
+	for hi-lo > lo*fitPrecision {
+		mid := (lo + hi) / 2
+		ok, err := fits(mid)
+		if err != nil {
+			return nil, 0, err
+		}
+		if ok {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+
+	opt.Size = lo
+	if fit.Wrap {
+		opt.MaxWidth = width
+		if opt.Vertical {
+			opt.MaxWidth = height
+		}
+	}
+	outlines, err := textOutlines(fonts, s, opt)
+	return outlines, lo, err
+}
+
+// measureText returns the width and height of laid out text, using either
+// its advances and line heights or the bounds of its outlines.
+func measureText(fonts []*ParsedFont, s string, opt Options, advance bool) (w, h float64, err error) {
+	if !advance {
+		outlines, err := textOutlines(fonts, s, opt)
+		if err != nil || len(outlines) == 0 {
+			return 0, 0, err
+		}
+		minX, minY, maxX, maxY := contourBounds(outlines)
+		return maxX - minX, maxY - minY, nil
+	}
+	layout, err := layoutText(fonts, s, opt)
+	if err != nil {
+		return 0, 0, err
+	}
+	var length float64
+	for _, line := range layout.lines {
+		length = math.Max(length, line.advance)
+	}
+	var across float64
+	if n := len(layout.lines); n > 0 {
+		across = layout.lines[0].baseline - layout.lines[n-1].baseline + layout.lines[n-1].height
+	}
+	if opt.Vertical {
+		return across, length, nil
+	}
+	return length, across, nil
+}
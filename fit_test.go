@@ -0,0 +1,70 @@
+package textcurve
+
+import (
+	"testing"
+)
+
+func TestFitText(t *testing.T) {
+	font := parseTestFont(t)
+
+	checkFit := func(name, text string, opt Options, fit FitOptions) float64 {
+		outlines, size, err := FitText(font, text, opt, fit)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		min, max := outlinesBounds(outlines)
+		w, h := max.X-min.X, max.Y-min.Y
+		maxW, maxH := fit.Width-2*fit.Padding, fit.Height-2*fit.Padding
+		if w > maxW || h > maxH {
+			t.Errorf("%s: %fx%f does not fit in %fx%f", name, w, h, maxW, maxH)
+		}
+
+		// A slightly larger size should not fit.
+		opt.Size = size * 1.01
+		if fit.Wrap {
+			opt.MaxWidth = maxW
+		}
+		bigW, bigH, err := measureText([]*ParsedFont{font}, text, opt, fit.Advance)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bigW <= maxW && bigH <= maxH {
+			t.Errorf("%s: size %f is not the largest that fits", name, size)
+		}
+		return size
+	}
+
+	checkFit("wide", "Hello", Options{}, FitOptions{Width: 40, Height: 30, Padding: 1})
+	checkFit("tall", "Hello", Options{}, FitOptions{Width: 40, Height: 8, Padding: 1})
+	checkFit("advance", "Hello", Options{}, FitOptions{Width: 40, Height: 8, Advance: true})
+	checkFit("embolden", "Hello", Options{Embolden: 0.5}, FitOptions{Width: 40, Height: 8})
+	checkFit("multiline", "Hello\nworld", Options{}, FitOptions{Width: 40, Height: 20})
+
+	text := "The quick brown fox jumps over the lazy dog"
+	unwrapped := checkFit("unwrapped", text, Options{}, FitOptions{Width: 40, Height: 40})
+	wrapped := checkFit("wrapped", text, Options{}, FitOptions{Width: 40, Height: 40, Wrap: true})
+	if wrapped <= unwrapped {
+		t.Errorf("wrapping should allow larger text: %f <= %f", wrapped, unwrapped)
+	}
+
+	// Font sets fit like their primary font when it covers the text.
+	fit := FitOptions{Width: 40, Height: 30}
+	_, size, err := FitText(font, "Hello", Options{}, fit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, setSize, err := FitText(NewFontSet(font), "Hello", Options{}, fit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if setSize != size {
+		t.Errorf("expected size %f for font set but got %f", size, setSize)
+	}
+
+	if _, _, err := FitText(font, "Hello", Options{}, FitOptions{Width: 10, Height: 10, Padding: 5}); err == nil {
+		t.Error("expected an error for an empty box")
+	}
+	if _, _, err := FitText(font, " ", Options{}, FitOptions{Width: 10, Height: 10}); err == nil {
+		t.Error("expected an error for text without outlines")
+	}
+}
@@ -0,0 +1,123 @@
+package textcurve
+
+import (
+	"sort"
+
+	"github.com/unixpickle/model3d/model2d"
+)
+
+// Glyph is one shaped glyph of laid out text, positioned as in the output
+// of TextOutlines.
+type Glyph struct {
+	// Index is the glyph index in its font, and Font is the index of the
+	// font in the FontSet, or 0 for a single font.
+	Index int
+	Font  int
+
+	// Start and End are the rune offsets of the glyph's cluster in the
+	// text. Ligatures cover several runes, and all glyphs of a cluster,
+	// such as a base and its marks, share the same range.
+	Start, End int
+
+	// Line is the index of the glyph's line, or column in vertical text.
+	Line int
+
+	// RTL is set for glyphs of right-to-left runs.
+	RTL bool
+
+	// Pen is the pen position in model units, and Offset moves the glyph
+	// origin from the pen, e.g. to attach marks. Outlines are drawn at
+	// Pen+Offset.
+	Pen    model2d.Coord
+	Offset model2d.Coord
+
+	// Advance is the distance that the glyph moves the pen along its line,
+	// including spacing.
+	Advance float64
+
+	// Min and Max bound the glyph's outlines. They are zero for glyphs
+	// without outlines, such as spaces.
+	Min, Max model2d.Coord
+
+	Outlines Outlines
+}
+
+// TextGlyphs lays out text like TextOutlines, but returns the glyphs
+// separately, line by line in visual order. Glyphs without outlines are
+// included as well, so their positions and advances can be inspected.
+//
+// The font is a *ParsedFont, or a *FontSet for glyph fallback.
+func TextGlyphs(font FontSource, s string, opt Options) ([]Glyph, error) {
+	fonts, err := sourceFonts(font)
+	if err != nil {
+		return nil, err
+	}
+	layout, err := layoutText(fonts, s, opt)
+	if err != nil {
+		return nil, err
+	}
+	glyphs, _ := layout.glyphs()
+	return glyphs, nil
+}
+
+// glyphs draws each glyph of the layout and aligns them together, returning
+// the glyphs and the translation applied to each line as by align.
+func (l *textLayout) glyphs() ([]Glyph, []model2d.Coord) {
+	var res []Glyph
+	var items []lineContours
+	for i, line := range l.lines {
+		ends := line.clusterEnds()
+		for _, g := range line.glyphs {
+			glyph := Glyph{
+				Index:   int(g.index),
+				Font:    g.fontIndex,
+				Start:   line.start + g.cluster,
+				End:     line.start + ends[g.cluster],
+				Line:    i,
+				RTL:     g.rtl,
+				Pen:     model2d.XY(g.penX-g.offsetX, g.penY-g.offsetY).Scale(g.scale),
+				Offset:  model2d.XY(g.offsetX, g.offsetY).Scale(g.scale),
+				Advance: g.advance,
+			}
+			if contours, ok := g.contours(l.opt.CurveSegs); ok && len(contours) > 0 {
+				glyph.Outlines = synthesizeStyle(contours, l.opt)
+				items = append(items, lineContours{line: i, contours: glyph.Outlines})
+			}
+			res = append(res, glyph)
+		}
+	}
+
+	offsets := l.align(items)
+	for i := range res {
+		g := &res[i]
+		g.Pen = g.Pen.Add(offsets[g.Line])
+		if len(g.Outlines) > 0 {
+			minX, minY, maxX, maxY := contourBounds(g.Outlines)
+			g.Min, g.Max = model2d.XY(minX, minY), model2d.XY(maxX, maxY)
+		}
+	}
+	return res, offsets
+}
+
+// clusterEnds maps the first rune of each cluster of the line to the end of
+// the cluster, relative to the start of the line.
+func (l *layoutLine) clusterEnds() map[int]int {
+	var starts []int
+	seen := map[int]bool{}
+	for _, g := range l.glyphs {
+		if !seen[g.cluster] {
+			seen[g.cluster] = true
+			starts = append(starts, g.cluster)
+		}
+	}
+	sort.Ints(starts)
+	res := map[int]int{}
+	for i, start := range starts {
+		if i+1 < len(starts) {
+			res[start] = starts[i+1]
+		} else {
+			res[start] = l.end - l.start
+		}
+	}
+	return res
+}
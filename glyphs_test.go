@@ -0,0 +1,75 @@
+package textcurve
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestTextGlyphs(t *testing.T) {
+	font := parseTestFont(t)
+
+	opt := Options{Size: 10, Kerning: true, Align: Align{HAlign: HAlignCenter, VAlign: VAlignCenter}}
+	text := "To be\nor not"
+	glyphs, err := TextGlyphs(font, text, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := TextOutlines(font, text, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actual Outlines
+	for _, g := range glyphs {
+		actual = append(actual, g.Outlines...)
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Error("glyph outlines do not match TextOutlines")
+	}
+
+	var ranges [][3]int
+	for _, g := range glyphs {
+		ranges = append(ranges, [3]int{g.Start, g.End, g.Line})
+	}
+	expectedRanges := [][3]int{
+		{0, 1, 0}, {1, 2, 0}, {2, 3, 0}, {3, 4, 0}, {4, 5, 0},
+		{6, 7, 1}, {7, 8, 1}, {8, 9, 1}, {9, 10, 1}, {10, 11, 1}, {11, 12, 1},
+	}
+	if !reflect.DeepEqual(ranges, expectedRanges) {
+		t.Errorf("expected ranges %v but got %v", expectedRanges, ranges)
+	}
+
+	for i, g := range glyphs {
+		if text[g.Start] == ' ' {
+			if g.Outlines != nil || g.Min != g.Max {
+				t.Errorf("glyph %d: expected no outlines for space", i)
+			}
+		} else {
+			min, max := outlinesBounds(g.Outlines)
+			if min != g.Min || max != g.Max {
+				t.Errorf("glyph %d: expected bounds %v-%v but got %v-%v", i, min, max, g.Min, g.Max)
+			}
+		}
+		if i+1 < len(glyphs) && glyphs[i+1].Line == g.Line {
+			next := glyphs[i+1]
+			if math.Abs(next.Pen.X-(g.Pen.X+g.Advance)) > 1e-8 || next.Pen.Y != g.Pen.Y {
+				t.Errorf("glyph %d: pen %v does not advance to %v", i, g.Pen, next.Pen)
+			}
+		}
+	}
+	if glyphs[0].Pen.Y <= glyphs[len(glyphs)-1].Pen.Y {
+		t.Error("expected second line below the first")
+	}
+
+	// Left and baseline alignment keeps the first pen at the origin.
+	glyphs, err = TextGlyphs(font, "Hi", Options{Size: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if glyphs[0].Pen.Norm() > 1e-8 || glyphs[1].Pen.Y != 0 || glyphs[1].Pen.X <= 0 {
+		t.Errorf("unexpected pens %v and %v", glyphs[0].Pen, glyphs[1].Pen)
+	}
+	if glyphs[0].Index != 43 || glyphs[1].Index != 76 {
+		t.Errorf("unexpected glyph indices %d and %d", glyphs[0].Index, glyphs[1].Index)
+	}
+}
@@ -3,9 +3,11 @@ module github.com/unixpickle/textcurve
 go 1.24.0
 
 require (
+	github.com/andybalholm/brotli v1.2.0
 	github.com/golang/freetype v0.0.0-20170609003504-e2365dfdc4a0
 	github.com/unixpickle/model3d v0.4.8
 	golang.org/x/image v0.36.0
+	golang.org/x/text v0.34.0
 )
 
 require (
@@ -13,5 +15,4 @@ require (
 	github.com/pkg/errors v0.9.1 // indirect
 	github.com/unixpickle/essentials v1.3.0 // indirect
 	github.com/unixpickle/splaytree v1.1.0 // indirect
-	golang.org/x/text v0.34.0 // indirect
 )
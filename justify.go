@@ -0,0 +1,58 @@
+package textcurve
+
+import "unicode"
+
+// justify stretches a line to opt.MaxWidth by moving its glyphs apart.
+//
+// The space is split between the word separators of the line. Lines without
+// separators, such as long single words, get up to opt.MaxTracking between
+// their grapheme clusters instead, and may end up shorter than the width.
+func (l *layoutLine) justify(runes []rune, opt Options) {
+	space := opt.MaxWidth - l.advance
+	if space <= 0 || len(l.glyphs) == 0 {
+		return
+	}
+
+	// Leading and trailing separators are not stretched.
+	first, last := 0, len(l.glyphs)-1
+	for first <= last && l.isSeparator(first, runes) {
+		first++
+	}
+	for last >= first && l.isSeparator(last, runes) {
+		last--
+	}
+	if first >= last {
+		return
+	}
+
+	var separators, clusterGaps int
+	for i := first; i < last; i++ {
+		if l.isSeparator(i, runes) {
+			separators++
+		} else if l.glyphs[i+1].cluster != l.glyphs[i].cluster {
+			clusterGaps++
+		}
+	}
+	var tracking, wordSpacing float64
+	if separators > 0 {
+		wordSpacing = space / float64(separators)
+	} else if clusterGaps > 0 {
+		tracking = min(opt.MaxTracking, space/float64(clusterGaps))
+	}
+
+	extra := make([]float64, len(l.glyphs))
+	for i := first; i < last; i++ {
+		if l.isSeparator(i, runes) {
+			extra[i] = wordSpacing
+		} else if l.glyphs[i+1].cluster != l.glyphs[i].cluster {
+			extra[i] = tracking
+		}
+	}
+	l.addAdvances(extra, opt.Vertical)
+}
+
+// isSeparator reports whether the i-th glyph draws a word separator.
+func (l *layoutLine) isSeparator(i int, runes []rune) bool {
+	c := l.glyphs[i].cluster
+	return c >= 0 && c < len(runes) && unicode.Is(unicode.Zs, runes[c])
+}
@@ -0,0 +1,106 @@
+package textcurve
+
+import (
+	"math"
+	"testing"
+)
+
+func TestJustify(t *testing.T) {
+	font := parseTestFont(t)
+	text := "The quick brown fox jumps over the lazy dog\nend"
+	opt := Options{Size: 10, MaxWidth: 60, Align: Align{HAlign: HAlignJustify}}
+	layout, err := layoutText([]*ParsedFont{font}, text, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	startOpt := opt
+	startOpt.Align.HAlign = HAlignStart
+	unjustified, err := layoutText([]*ParsedFont{font}, text, startOpt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(layout.lines) != len(unjustified.lines) {
+		t.Fatalf("expected %d lines but got %d", len(unjustified.lines), len(layout.lines))
+	}
+
+	// The last line of each paragraph is not justified, and neither are
+	// single words without tracking.
+	lastLines := map[int]bool{len(layout.lines) - 2: true, len(layout.lines) - 1: true}
+	for i, line := range layout.lines {
+		last := line.glyphs[len(line.glyphs)-1]
+		right := last.penX*last.scale + last.advance
+		hasSpace := false
+		for _, g := range line.glyphs {
+			hasSpace = hasSpace || g.index == 3
+		}
+		if lastLines[i] || !hasSpace {
+			if line.advance != unjustified.lines[i].advance {
+				t.Errorf("line %d: should not be justified", i)
+			}
+			continue
+		}
+		if math.Abs(line.advance-opt.MaxWidth) > 1e-6 || math.Abs(right-opt.MaxWidth) > 1e-6 {
+			t.Errorf("line %d: expected width %f but got advance %f and right edge %f", i,
+				opt.MaxWidth, line.advance, right)
+		}
+		// Only the gaps between words are widened.
+		for j, g := range line.glyphs {
+			expected := unjustified.lines[i].glyphs[j].advance
+			if g.index != 3 && math.Abs(g.advance-expected) > 1e-6 {
+				t.Errorf("line %d: glyph %d was widened from %f to %f", i, j, expected, g.advance)
+			}
+		}
+	}
+
+	// Tracking applies within words, up to its limit.
+	opt = Options{Size: 10, MaxWidth: 30, MaxTracking: 0.5, Align: Align{HAlign: HAlignJustify}}
+	layout, err = layoutText([]*ParsedFont{font}, "abc abc", opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unjustified, err = layoutText([]*ParsedFont{font}, "abc abc", Options{Size: 10, MaxWidth: 30})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(layout.lines) != 2 {
+		t.Fatalf("expected 2 lines but got %d", len(layout.lines))
+	}
+	if actual, expected := layout.lines[0].advance, unjustified.lines[0].advance+1; math.Abs(actual-expected) > 1e-6 {
+		t.Errorf("expected tracked advance %f but got %f", expected, actual)
+	}
+
+	// Lines with several words are justified by their word gaps, even when
+	// tracking is allowed.
+	text = "abcdefgh abcdefgh abcdefgh end"
+	opt = Options{Size: 10, MaxWidth: 190, MaxTracking: 0.5, Align: Align{HAlign: HAlignJustify}}
+	layout, err = layoutText([]*ParsedFont{font}, text, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unjustified, err = layoutText([]*ParsedFont{font}, text, Options{Size: 10, MaxWidth: 190})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(layout.lines) != 2 || len(layout.lines[0].glyphs) != 26 {
+		t.Fatal("expected three words on the first of 2 lines")
+	}
+	var wordGrowth, letterGrowth float64
+	for j, g := range layout.lines[0].glyphs {
+		growth := g.advance - unjustified.lines[0].glyphs[j].advance
+		if g.index == 3 {
+			wordGrowth = math.Max(wordGrowth, growth)
+		} else {
+			letterGrowth = math.Max(letterGrowth, growth)
+		}
+	}
+	if wordGrowth <= letterGrowth || letterGrowth > 1e-8 {
+		t.Errorf("word gaps grew by %f and letter gaps by %f", wordGrowth, letterGrowth)
+	}
+	if math.Abs(layout.lines[0].advance-opt.MaxWidth) > 1e-6 {
+		t.Errorf("expected width %f but got %f", opt.MaxWidth, layout.lines[0].advance)
+	}
+
+	if _, err := TextOutlines(font, "abc", Options{Size: 10, Align: Align{HAlign: HAlignJustify}}); err == nil {
+		t.Error("expected an error without MaxWidth")
+	}
+}
@@ -0,0 +1,163 @@
+package textcurve
+
+import (
+	"encoding/binary"
+	"errors"
+
+	gotextfont "github.com/go-text/typesetting/font"
+)
+
+// FontInfo holds the names and style attributes of a font.
+type FontInfo struct {
+	Family         string
+	Subfamily      string
+	FullName       string
+	PostScriptName string
+
+	WeightClass int     // OS/2 usWeightClass, e.g. WeightBold
+	ItalicAngle float64 // degrees counter-clockwise from vertical
+}
+
+// FontMetrics holds the vertical metrics of a font.
+//
+// Positions are relative to the baseline with y pointing up, so descenders
+// and underline positions are usually negative. WinDescent follows the
+// same convention, although the OS/2 table stores it as a positive value.
+type FontMetrics struct {
+	UnitsPerEm float64 // size of the em square
+
+	TypoAscender  float64
+	TypoDescender float64
+	TypoLineGap   float64
+
+	HheaAscender  float64
+	HheaDescender float64
+	HheaLineGap   float64
+
+	WinAscent  float64
+	WinDescent float64
+
+	CapHeight float64
+	XHeight   float64
+
+	UnderlinePosition  float64
+	UnderlineThickness float64
+	StrikeoutPosition  float64
+	StrikeoutThickness float64
+}
+
+// Scale returns the metrics multiplied by s.
+func (m FontMetrics) Scale(s float64) FontMetrics {
+	return FontMetrics{
+		UnitsPerEm:         m.UnitsPerEm * s,
+		TypoAscender:       m.TypoAscender * s,
+		TypoDescender:      m.TypoDescender * s,
+		TypoLineGap:        m.TypoLineGap * s,
+		HheaAscender:       m.HheaAscender * s,
+		HheaDescender:      m.HheaDescender * s,
+		HheaLineGap:        m.HheaLineGap * s,
+		WinAscent:          m.WinAscent * s,
+		WinDescent:         m.WinDescent * s,
+		CapHeight:          m.CapHeight * s,
+		XHeight:            m.XHeight * s,
+		UnderlinePosition:  m.UnderlinePosition * s,
+		UnderlineThickness: m.UnderlineThickness * s,
+		StrikeoutPosition:  m.StrikeoutPosition * s,
+		StrikeoutThickness: m.StrikeoutThickness * s,
+	}
+}
+
+// Info returns the names and style attributes of the font.
+func (p *ParsedFont) Info() FontInfo {
+	return p.info
+}
+
+// Metrics returns the vertical metrics of the font in font units.
+func (p *ParsedFont) Metrics() FontMetrics {
+	return p.metrics
+}
+
+// ScaledMetrics returns the vertical metrics of the font in model units,
+// using the same scale as TextOutlines for opt.Size.
+//
+// If opt.Variations is set, the metrics are those of the variable font
+// instance.
+func (p *ParsedFont) ScaledMetrics(opt Options) (FontMetrics, error) {
+	if opt.Size <= 0 {
+		return FontMetrics{}, errors.New("Size must be > 0")
+	}
+	font, err := p.instance(opt)
+	if err != nil {
+		return FontMetrics{}, err
+	}
+	return font.metrics.Scale(font.sizeScale(opt.Size)), nil
+}
+
+// parseFontInfo reads the name, OS/2 and post tables of a face.
+func parseFontInfo(data []byte, dirOffset int) FontInfo {
+	var info FontInfo
+	if table, ok := findSFNTTable(data, dirOffset, "name"); ok {
+		names := parseNameTable(table)
+		info.Family = firstNonEmpty(names[nameIDTypographicFamily], names[nameIDFamily])
+		info.Subfamily = firstNonEmpty(names[nameIDTypographicSubfamily], names[nameIDSubfamily])
+		info.FullName = names[nameIDFullName]
+		info.PostScriptName = names[nameIDPostScriptName]
+	}
+	if os2, ok := findSFNTTable(data, dirOffset, "OS/2"); ok && len(os2) >= 6 {
+		info.WeightClass = int(binary.BigEndian.Uint16(os2[4:6]))
+	}
+	if post, ok := findSFNTTable(data, dirOffset, "post"); ok && len(post) >= 8 {
+		info.ItalicAngle = float64(int32(binary.BigEndian.Uint32(post[4:8]))) / 65536
+	}
+	return info
+}
+
+// parseFontMetrics reads the head, hhea, OS/2 and post metrics of a face.
+func parseFontMetrics(data []byte, dirOffset int) FontMetrics {
+	var m FontMetrics
+	i16 := func(b []byte, off int) float64 {
+		return float64(int16(binary.BigEndian.Uint16(b[off:])))
+	}
+	if head, ok := findSFNTTable(data, dirOffset, "head"); ok && len(head) >= 20 {
+		m.UnitsPerEm = float64(binary.BigEndian.Uint16(head[18:20]))
+	}
+	if hhea, ok := findSFNTTable(data, dirOffset, "hhea"); ok && len(hhea) >= 10 {
+		m.HheaAscender = i16(hhea, 4)
+		m.HheaDescender = i16(hhea, 6)
+		m.HheaLineGap = i16(hhea, 8)
+	}
+	if os2, ok := findSFNTTable(data, dirOffset, "OS/2"); ok {
+		if len(os2) >= 30 {
+			m.StrikeoutThickness = i16(os2, 26)
+			m.StrikeoutPosition = i16(os2, 28)
+		}
+		if len(os2) >= 78 {
+			m.TypoAscender = i16(os2, 68)
+			m.TypoDescender = i16(os2, 70)
+			m.TypoLineGap = i16(os2, 72)
+			m.WinAscent = float64(binary.BigEndian.Uint16(os2[74:76]))
+			m.WinDescent = -float64(binary.BigEndian.Uint16(os2[76:78]))
+		}
+		if len(os2) >= 90 {
+			m.XHeight = i16(os2, 86)
+			m.CapHeight = i16(os2, 88)
+		}
+	}
+	if post, ok := findSFNTTable(data, dirOffset, "post"); ok && len(post) >= 12 {
+		m.UnderlinePosition = i16(post, 8)
+		m.UnderlineThickness = i16(post, 10)
+	}
+	return m
+}
+
+// applyFaceMetrics updates the metrics which go-text can compute for a
+// face, including MVAR deltas of variable font instances and cap/x-heights
+// measured from glyphs when the OS/2 table predates them.
+func (m *FontMetrics) applyFaceMetrics(face *gotextfont.Face) {
+	m.CapHeight = float64(face.LineMetric(gotextfont.CapHeight))
+	m.XHeight = float64(face.LineMetric(gotextfont.XHeight))
+	m.UnderlinePosition = float64(face.LineMetric(gotextfont.UnderlinePosition))
+	m.UnderlineThickness = float64(face.LineMetric(gotextfont.UnderlineThickness))
+	m.StrikeoutPosition = float64(face.LineMetric(gotextfont.StrikethroughPosition))
+	m.StrikeoutThickness = float64(face.LineMetric(gotextfont.StrikethroughThickness))
+}
@@ -0,0 +1,63 @@
+package textcurve
+
+import (
+	"math"
+	"testing"
+)
+
+func TestFontMetadata(t *testing.T) {
+	font := parseTestFont(t)
+
+	info := font.Info()
+	expectedInfo := FontInfo{
+		Family:         "Liberation Sans",
+		Subfamily:      "Regular",
+		FullName:       "Liberation Sans",
+		PostScriptName: "LiberationSans",
+		WeightClass:    WeightRegular,
+	}
+	if info != expectedInfo {
+		t.Errorf("expected info %+v but got %+v", expectedInfo, info)
+	}
+	if font.UnitsPerEm() != 2048 {
+		t.Errorf("unexpected units per em: %f", font.UnitsPerEm())
+	}
+
+	metrics := font.Metrics()
+	expectedMetrics := FontMetrics{
+		UnitsPerEm:         2048,
+		TypoAscender:       1491,
+		TypoDescender:      -431,
+		TypoLineGap:        307,
+		HheaAscender:       1854,
+		HheaDescender:      -434,
+		HheaLineGap:        67,
+		WinAscent:          1854,
+		WinDescent:         -434,
+		CapHeight:          1409,
+		XHeight:            1082,
+		UnderlinePosition:  -217,
+		UnderlineThickness: 150,
+		StrikeoutPosition:  530,
+		StrikeoutThickness: 102,
+	}
+	if metrics != expectedMetrics {
+		t.Errorf("expected metrics %+v but got %+v", expectedMetrics, metrics)
+	}
+
+	scaled, err := font.ScaledMetrics(Options{Size: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Options.Size is the typo ascender in model units.
+	if math.Abs(scaled.TypoAscender-10) > 1e-8 {
+		t.Errorf("unexpected scaled ascender: %f", scaled.TypoAscender)
+	}
+	scale := 10 / expectedMetrics.TypoAscender
+	if math.Abs(scaled.CapHeight-expectedMetrics.CapHeight*scale) > 1e-8 {
+		t.Errorf("unexpected scaled cap height: %f", scaled.CapHeight)
+	}
+	if _, err := font.ScaledMetrics(Options{}); err == nil {
+		t.Error("expected error for zero size")
+	}
+}
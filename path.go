@@ -0,0 +1,255 @@
+package textcurve
+
+import (
+	"errors"
+	"math"
+	"sort"
+
+	"github.com/unixpickle/model3d/model2d"
+)
+
+// Path is a curve which text can follow, parameterized by arc length.
+type Path interface {
+	// Length returns the arc length of the path.
+	Length() float64
+
+	// At returns the point at arc length s along the path and the unit
+	// tangent there. Values of s outside [0, Length()] extend the path
+	// past its ends, or wrap around closed paths.
+	At(s float64) (point, tangent model2d.Coord)
+}
+
+// PolylinePath is a path through a sequence of points.
+//
+// If the first and last points are equal, the path is closed.
+type PolylinePath struct {
+	points  []model2d.Coord
+	lengths []float64 // cumulative arc length at each point
+}
+
+// NewPolylinePath creates a path through the given points, skipping
+// repeated points.
+func NewPolylinePath(points []model2d.Coord) *PolylinePath {
+	res := &PolylinePath{}
+	for _, p := range points {
+		if n := len(res.points); n > 0 {
+			if p == res.points[n-1] {
+				continue
+			}
+			res.lengths = append(res.lengths, res.lengths[n-1]+p.Dist(res.points[n-1]))
+		} else {
+			res.lengths = append(res.lengths, 0)
+		}
+		res.points = append(res.points, p)
+	}
+	return res
+}
+
+// NewCurvePath approximates a parametric curve, evaluated for t in [0, 1],
+// with a polyline of the given number of segments.
+func NewCurvePath(curve model2d.Curve, segments int) *PolylinePath {
+	points := make([]model2d.Coord, segments+1)
+	for i := range points {
+		points[i] = curve.Eval(float64(i) / float64(segments))
+	}
+	return NewPolylinePath(points)
+}
+
+// Length returns the total length of the polyline.
+func (p *PolylinePath) Length() float64 {
+	if len(p.lengths) == 0 {
+		return 0
+	}
+	return p.lengths[len(p.lengths)-1]
+}
+
+// At returns the point at arc length s and the direction of its segment.
+func (p *PolylinePath) At(s float64) (point, tangent model2d.Coord) {
+	n := len(p.points)
+	if n == 0 {
+		return model2d.Coord{}, model2d.XY(1, 0)
+	} else if n == 1 {
+		return p.points[0].Add(model2d.XY(s, 0)), model2d.XY(1, 0)
+	}
+	length := p.Length()
+	if p.points[0] == p.points[n-1] {
+		s = math.Mod(s, length)
+		if s < 0 {
+			s += length
+		}
+	}
+	// Find the segment containing s, extending the first and last ones.
+	i := sort.SearchFloat64s(p.lengths, s) - 1
+	i = max(0, min(i, n-2))
+	tangent = p.points[i+1].Sub(p.points[i]).Normalize()
+	point = p.points[i].Add(tangent.Scale(s - p.lengths[i]))
+	return point, tangent
+}
+
+// ArcPath is a circular arc, going counter-clockwise from Start to End if
+// End > Start, or clockwise otherwise. Angles are in radians, and Radius
+// must be positive.
+type ArcPath struct {
+	Center model2d.Coord
+	Radius float64
+	Start  float64
+	End    float64
+}
+
+// Length returns the length of the arc.
+func (a *ArcPath) Length() float64 {
+	return math.Abs(a.End-a.Start) * a.Radius
+}
+
+// At returns the point at arc length s. The arc continues along its circle
+// past its ends.
+func (a *ArcPath) At(s float64) (point, tangent model2d.Coord) {
+	dir := 1.0
+	if a.End < a.Start {
+		dir = -1
+	}
+	theta := a.Start + dir*s/a.Radius
+	sin, cos := math.Sincos(theta)
+	point = a.Center.Add(model2d.XY(cos, sin).Scale(a.Radius))
+	tangent = model2d.XY(-sin, cos).Scale(dir)
+	return point, tangent
+}
+
+// reversedPath traverses a path backwards.
+type reversedPath struct {
+	Path
+}
+
+func (r reversedPath) At(s float64) (point, tangent model2d.Coord) {
+	point, tangent = r.Path.At(r.Path.Length() - s)
+	return point, tangent.Scale(-1)
+}
+
+// PathAlign controls where text is placed along a path.
+type PathAlign int
+
+const (
+	// PathAlignStart starts text at PathOptions.Offset.
+	PathAlignStart PathAlign = iota
+	// PathAlignCenter centers text on the middle of the path, moved
+	// forward by PathOptions.Offset.
+	PathAlignCenter
+	// PathAlignEnd ends text at PathOptions.Offset before the end of the
+	// path.
+	PathAlignEnd
+)
+
+// PathSide controls which side of a path text is drawn on.
+type PathSide int
+
+const (
+	// PathSideLeft draws text on the left of the path, so that text along
+	// a path going right stands upright on it.
+	PathSideLeft PathSide = iota
+	// PathSideRight draws text on the right of the path, reading from the
+	// end of the path to its start.
+	PathSideRight
+)
+
+// PathOptions controls how text follows a path.
+type PathOptions struct {
+	// Offset is the arc length in model units by which text is moved
+	// away from the point selected by Align.
+	Offset float64
+	Align  PathAlign
+	Side   PathSide
+}
+
+// TextOutlinesOnPath is like TextOutlines, but lays each line along a path.
+//
+// Each glyph is moved to the point of the path at its arc length and
+// rotated to the local tangent, with its baseline on the path. Options.Align
+// only applies vertically, moving text across the path, and later lines
+// are drawn beside the path. Vertical text is not supported.
+//
+// The font is a *ParsedFont, or a *FontSet for glyph fallback.
+func TextOutlinesOnPath(font FontSource, s string, path Path, opt Options,
+	pathOpt PathOptions) (Outlines, error) {
+	fonts, err := sourceFonts(font)
+	if err != nil {
+		return nil, err
+	}
+	if path == nil {
+		return nil, errors.New("nil path")
+	}
+	if arc, ok := path.(*ArcPath); ok && !(arc.Radius > 0) {
+		return nil, errors.New("ArcPath Radius must be > 0")
+	}
+	if length := path.Length(); math.IsNaN(length) || math.IsInf(length, 0) {
+		return nil, errors.New("path length must be finite")
+	}
+	if opt.Vertical {
+		return nil, errors.New("vertical text cannot follow a path")
+	}
+	layout, err := layoutText(fonts, s, opt)
+	if err != nil {
+		return nil, err
+	}
+	if pathOpt.Side == PathSideRight {
+		path = reversedPath{path}
+	}
+
+	// Glyphs are drawn around an anchor on the baseline, which is moved
+	// onto the path.
+	type pathGlyph struct {
+		contours []Contour
+		anchor   float64 // arc length
+	}
+	var glyphs []pathGlyph
+	var all []Contour
+	for _, line := range layout.lines {
+		var start float64
+		switch pathOpt.Align {
+		case PathAlignStart:
+			start = pathOpt.Offset
+		case PathAlignCenter:
+			start = (path.Length()-line.advance)/2 + pathOpt.Offset
+		case PathAlignEnd:
+			start = path.Length() - line.advance - pathOpt.Offset
+		default:
+			return nil, errors.New("unknown PathAlign")
+		}
+		// Marks stay attached to the base glyph of their cluster.
+		clusterAnchors := map[int]float64{}
+		for _, g := range line.glyphs {
+			if _, ok := clusterAnchors[g.cluster]; !ok && g.advance != 0 {
+				clusterAnchors[g.cluster] = g.penX*g.scale + g.advance/2
+			}
+		}
+		for _, g := range line.glyphs {
+			anchor := g.penX*g.scale + g.advance/2
+			if clusterAnchor, ok := clusterAnchors[g.cluster]; ok && g.advance == 0 {
+				anchor = clusterAnchor
+			}
+			contours, ok := g.contours(layout.opt.CurveSegs)
+			if !ok {
+				continue
+			}
+			contours = synthesizeStyle(contours, layout.opt)
+			translateContours(contours, -anchor, line.baseline)
+			glyphs = append(glyphs, pathGlyph{contours: contours, anchor: start + anchor})
+			all = append(all, contours...)
+		}
+	}
+	if len(all) == 0 {
+		return nil, nil
+	}
+
+	_, minY, _, maxY := contourBounds(all)
+	dy := layout.blockVAlign(minY, maxY)
+	for _, g := range glyphs {
+		point, tangent := path.At(g.anchor)
+		normal := model2d.XY(-tangent.Y, tangent.X)
+		for _, c := range g.contours {
+			for i, p := range c {
+				c[i] = point.Add(tangent.Scale(p.X)).Add(normal.Scale(p.Y + dy))
+			}
+		}
+	}
+	return all, nil
+}
@@ -0,0 +1,140 @@
+package textcurve
+
+import (
+	"math"
+	"testing"
+
+	"github.com/unixpickle/model3d/model2d"
+)
+
+func TestPolylinePath(t *testing.T) {
+	path := NewPolylinePath([]model2d.Coord{model2d.XY(0, 0), model2d.XY(3, 0), model2d.XY(3, 0),
+		model2d.XY(3, 4)})
+	if l := path.Length(); math.Abs(l-7) > 1e-8 {
+		t.Fatalf("expected length 7 but got %f", l)
+	}
+	cases := []struct {
+		s       float64
+		point   model2d.Coord
+		tangent model2d.Coord
+	}{
+		{0, model2d.XY(0, 0), model2d.XY(1, 0)},
+		{2, model2d.XY(2, 0), model2d.XY(1, 0)},
+		{5, model2d.XY(3, 2), model2d.XY(0, 1)},
+		{-1, model2d.XY(-1, 0), model2d.XY(1, 0)},
+		{8, model2d.XY(3, 5), model2d.XY(0, 1)},
+	}
+	for _, c := range cases {
+		point, tangent := path.At(c.s)
+		if point.Dist(c.point) > 1e-8 || tangent.Dist(c.tangent) > 1e-8 {
+			t.Errorf("s=%f: expected %v, %v but got %v, %v", c.s, c.point, c.tangent, point, tangent)
+		}
+	}
+
+	closed := NewPolylinePath([]model2d.Coord{model2d.XY(0, 0), model2d.XY(1, 0), model2d.XY(1, 1),
+		model2d.XY(0, 1), model2d.XY(0, 0)})
+	if point, _ := closed.At(4.5); point.Dist(model2d.XY(0.5, 0)) > 1e-8 {
+		t.Errorf("closed path should wrap around, but got %v", point)
+	}
+	if point, _ := closed.At(-0.5); point.Dist(model2d.XY(0, 0.5)) > 1e-8 {
+		t.Errorf("closed path should wrap around, but got %v", point)
+	}
+
+	arc := &ArcPath{Center: model2d.XY(1, 1), Radius: 2, Start: 0, End: -math.Pi}
+	if l := arc.Length(); math.Abs(l-2*math.Pi) > 1e-8 {
+		t.Errorf("expected arc length %f but got %f", 2*math.Pi, l)
+	}
+	point, tangent := arc.At(math.Pi)
+	if point.Dist(model2d.XY(1, -1)) > 1e-8 || tangent.Dist(model2d.XY(-1, 0)) > 1e-8 {
+		t.Errorf("unexpected clockwise arc point %v and tangent %v", point, tangent)
+	}
+}
+
+func TestTextOutlinesOnPath(t *testing.T) {
+	font := parseTestFont(t)
+	opt := Options{Size: 10}
+	text := "Hello"
+	horizontal := func(h HAlign) Outlines {
+		opt := opt
+		opt.Align.HAlign = h
+		res, err := TextOutlines(font, text, opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+	onPath := func(path Path, pathOpt PathOptions) Outlines {
+		res, err := TextOutlinesOnPath(font, text, path, opt, pathOpt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+	layout, err := layoutText([]*ParsedFont{font}, text, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	advance := layout.lines[0].advance
+
+	line := NewPolylinePath([]model2d.Coord{model2d.XY(0, 0), model2d.XY(100, 0)})
+	assertSameBounds(t, "start", onPath(line, PathOptions{}), horizontal(HAlignLeft), 0)
+	assertSameBounds(t, "offset", onPath(line, PathOptions{Offset: 5}), horizontal(HAlignLeft), 5)
+	assertSameBounds(t, "center", onPath(line, PathOptions{Align: PathAlignCenter}),
+		horizontal(HAlignLeft), (100-advance)/2)
+	assertSameBounds(t, "end", onPath(line, PathOptions{Align: PathAlignEnd, Offset: 5}),
+		horizontal(HAlignRight), 95)
+
+	setOutlines, err := TextOutlinesOnPath(NewFontSet(font), text, line, opt, PathOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertSameBounds(t, "font set", setOutlines, horizontal(HAlignLeft), 0)
+
+	// On the right side, text is upside down from the end of the path.
+	hMin, hMax := outlinesBounds(horizontal(HAlignLeft))
+	rMin, rMax := outlinesBounds(onPath(line, PathOptions{Side: PathSideRight}))
+	expectedMin := model2d.XY(100-hMax.X, -hMax.Y)
+	expectedMax := model2d.XY(100-hMin.X, -hMin.Y)
+	if rMin.Dist(expectedMin) > 1e-6 || rMax.Dist(expectedMax) > 1e-6 {
+		t.Errorf("right side: expected bounds %v-%v but got %v-%v", expectedMin, expectedMax, rMin, rMax)
+	}
+
+	// Text going up the y axis is rotated counter-clockwise.
+	up := NewPolylinePath([]model2d.Coord{model2d.XY(0, 0), model2d.XY(0, 100)})
+	uMin, uMax := outlinesBounds(onPath(up, PathOptions{}))
+	expectedMin = model2d.XY(-hMax.Y, hMin.X)
+	expectedMax = model2d.XY(-hMin.Y, hMax.X)
+	if uMin.Dist(expectedMin) > 1e-6 || uMax.Dist(expectedMax) > 1e-6 {
+		t.Errorf("rotated: expected bounds %v-%v but got %v-%v", expectedMin, expectedMax, uMin, uMax)
+	}
+
+	// Text around a circle stays outside of it, within its ascent, apart
+	// from overshoot below the baseline.
+	radius := 30.0
+	circle := &ArcPath{Radius: radius, Start: math.Pi, End: 0}
+	for _, c := range onPath(circle, PathOptions{Align: PathAlignCenter}) {
+		for _, p := range c {
+			if r := p.Norm(); r < radius-0.5 || r > radius+opt.Size*1.5 {
+				t.Fatalf("point %v is at radius %f", p, r)
+			}
+			if p.Y < 0 {
+				t.Fatalf("point %v is below the arc", p)
+			}
+		}
+	}
+
+	// Degenerate arcs and paths of infinite length are rejected.
+	for name, path := range map[string]Path{
+		"zero radius":     &ArcPath{Radius: 0, End: 1},
+		"negative radius": &ArcPath{Radius: -10, End: 1},
+		"NaN radius":      &ArcPath{Radius: math.NaN(), End: 1},
+		"infinite arc":    &ArcPath{Radius: 10, End: math.Inf(1)},
+		"infinite line": NewPolylinePath([]model2d.Coord{
+			model2d.XY(0, 0), model2d.XY(math.Inf(1), 0),
+		}),
+	} {
+		if _, err := TextOutlinesOnPath(font, "ab", path, Options{Size: 3}, PathOptions{}); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
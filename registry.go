@@ -0,0 +1,499 @@
+package textcurve
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// OpenSCADFontPathEnv is the environment variable OpenSCAD reads extra
+// font directories from. See FontRegistry.AddEnvPath.
+const OpenSCADFontPathEnv = "OPENSCAD_FONT_PATH"
+
+// Common OpenType weight classes.
+const (
+	WeightThin       = 100
+	WeightExtraLight = 200
+	WeightLight      = 300
+	WeightRegular    = 400
+	WeightMedium     = 500
+	WeightSemiBold   = 600
+	WeightBold       = 700
+	WeightExtraBold  = 800
+	WeightBlack      = 900
+)
+
+// FontEntry describes one face indexed by a FontRegistry.
+type FontEntry struct {
+	Path  string
+	Index int // face index for collections
+
+	Family string
+	Style  string
+
+	Weight int  // OpenType weight class, e.g. WeightBold
+	Italic bool // italic or oblique
+
+	// Names holds every name the face can be matched by: the legacy and
+	// typographic family names, the full name and the PostScript name.
+	Names []string
+}
+
+// FontPattern is a parsed fontconfig-like font pattern.
+//
+// See ParseFontPattern for the accepted syntax.
+type FontPattern struct {
+	Families []string
+	Style    string
+
+	Weight int // 0 if unspecified
+	Slant  FontSlant
+}
+
+// FontSlant is the slant requested in a FontPattern.
+type FontSlant int
+
+const (
+	SlantAny FontSlant = iota
+	SlantRoman
+	SlantItalic
+)
+
+// ParseFontPattern parses a fontconfig-like pattern as used by OpenSCAD,
+// such as "Liberation Sans:style=Bold Italic".
+//
+// A pattern is a comma-separated list of families followed by
+// colon-separated properties. The supported properties are style=...,
+// weight=... (a name such as "bold", or a number on fontconfig's scale from
+// 0 to 215, where 80 is regular and 200 is bold) and slant=... ("roman",
+// "italic" or "oblique", or fontconfig's 0, 100 or 110). Bare weight and
+// slant names, as in "DejaVu Sans:bold:italic", are also accepted.
+// Backslashes escape the following character.
+func ParseFontPattern(pattern string) (*FontPattern, error) {
+	parts := splitEscaped(pattern, ':')
+	res := &FontPattern{}
+	for _, family := range splitEscaped(parts[0], ',') {
+		if family = strings.TrimSpace(unescapePattern(family)); family != "" {
+			res.Families = append(res.Families, family)
+		}
+	}
+	for _, prop := range parts[1:] {
+		prop = strings.TrimSpace(unescapePattern(prop))
+		if prop == "" {
+			continue
+		}
+		key, value, hasValue := strings.Cut(prop, "=")
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+		if !hasValue {
+			if w, ok := weightNames[key]; ok {
+				res.Weight = w
+			} else if s, ok := slantNames[key]; ok {
+				res.Slant = s
+			} else {
+				return nil, fmt.Errorf("unknown font property %q", prop)
+			}
+			continue
+		}
+		switch key {
+		case "style":
+			res.Style = value
+		case "weight":
+			if w, ok := weightNames[strings.ToLower(value)]; ok {
+				res.Weight = w
+			} else if w, err := strconv.ParseFloat(value, 64); err == nil && w >= 0 && w <= 215 {
+				res.Weight = fontconfigWeight(w)
+			} else {
+				return nil, fmt.Errorf("invalid font weight %q", value)
+			}
+		case "slant":
+			if s, ok := slantNames[strings.ToLower(value)]; ok {
+				res.Slant = s
+			} else if s, ok := fontconfigSlants[value]; ok {
+				res.Slant = s
+			} else {
+				return nil, fmt.Errorf("invalid font slant %q", value)
+			}
+		default:
+			// Ignore other fontconfig properties (size, lang, etc.).
+		}
+	}
+	return res, nil
+}
+
+var weightNames = map[string]int{
+	"thin":       WeightThin,
+	"hairline":   WeightThin,
+	"extralight": WeightExtraLight,
+	"ultralight": WeightExtraLight,
+	"light":      WeightLight,
+	"book":       WeightRegular,
+	"normal":     WeightRegular,
+	"regular":    WeightRegular,
+	"medium":     WeightMedium,
+	"semibold":   WeightSemiBold,
+	"demibold":   WeightSemiBold,
+	"bold":       WeightBold,
+	"extrabold":  WeightExtraBold,
+	"ultrabold":  WeightExtraBold,
+	"black":      WeightBlack,
+	"heavy":      WeightBlack,
+}
+
+var slantNames = map[string]FontSlant{
+	"roman":   SlantRoman,
+	"italic":  SlantItalic,
+	"oblique": SlantItalic,
+}
+
+// fontconfigSlants maps fontconfig's numeric slants to slants.
+var fontconfigSlants = map[string]FontSlant{
+	"0":   SlantRoman,
+	"100": SlantItalic,
+	"110": SlantItalic,
+}
+
+// fontconfigWeights maps fontconfig's weight constants to OpenType weight
+// classes, in increasing order.
+var fontconfigWeights = []struct {
+	fc, ot float64
+}{
+	{0, WeightThin},
+	{40, WeightExtraLight},
+	{50, WeightLight},
+	{55, 350},
+	{75, 380},
+	{80, WeightRegular},
+	{100, WeightMedium},
+	{180, WeightSemiBold},
+	{200, WeightBold},
+	{205, WeightExtraBold},
+	{210, WeightBlack},
+	{215, 1000},
+}
+
+// fontconfigWeight converts a weight on fontconfig's scale to an OpenType
+// weight class, interpolating between the named weights like fontconfig.
+func fontconfigWeight(w float64) int {
+	for i := 1; i < len(fontconfigWeights); i++ {
+		lo, hi := fontconfigWeights[i-1], fontconfigWeights[i]
+		if w <= hi.fc {
+			t := (w - lo.fc) / (hi.fc - lo.fc)
+			return int(math.Round(lo.ot + t*(hi.ot-lo.ot)))
+		}
+	}
+	return int(fontconfigWeights[len(fontconfigWeights)-1].ot)
+}
+
+// styleWeightSlant infers a weight and slant from a style name such as
+// "Semibold Italic".
+func styleWeightSlant(style string) (weight int, slant FontSlant) {
+	weight, slant = WeightRegular, SlantRoman
+	normalized := strings.ToLower(strings.NewReplacer(" ", "", "-", "", "_", "").Replace(style))
+	bestLen := 0
+	for name, w := range weightNames {
+		if len(name) > bestLen && strings.Contains(normalized, name) {
+			weight, bestLen = w, len(name)
+		}
+	}
+	if strings.Contains(normalized, "italic") || strings.Contains(normalized, "oblique") {
+		slant = SlantItalic
+	}
+	return weight, slant
+}
+
+func splitEscaped(s string, sep byte) []string {
+	var res []string
+	start := 0
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' {
+			i++
+		} else if s[i] == sep {
+			res = append(res, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(res, s[start:])
+}
+
+func unescapePattern(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// FontRegistry indexes font files by family and style, and resolves
+// OpenSCAD-style font names to parsed fonts.
+//
+// A FontRegistry is safe for concurrent use.
+type FontRegistry struct {
+	lock    sync.Mutex
+	entries []FontEntry
+	fonts   map[fontKey]*ParsedFont
+}
+
+type fontKey struct {
+	path  string
+	index int
+}
+
+// NewFontRegistry creates a registry indexing the given directories.
+func NewFontRegistry(dirs ...string) (*FontRegistry, error) {
+	r := &FontRegistry{fonts: map[fontKey]*ParsedFont{}}
+	for _, dir := range dirs {
+		if err := r.AddDir(dir); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// AddEnvPath indexes every directory listed in an environment variable,
+// separated by os.PathListSeparator, such as OpenSCADFontPathEnv.
+// Missing directories are skipped.
+func (r *FontRegistry) AddEnvPath(name string) error {
+	for _, dir := range filepath.SplitList(os.Getenv(name)) {
+		if dir == "" {
+			continue
+		}
+		if _, err := os.Stat(dir); errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err := r.AddDir(dir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddDir recursively indexes the font files in a directory.
+// Files which cannot be parsed as fonts are skipped.
+func (r *FontRegistry) AddDir(dir string) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !isFontFileName(path) {
+			return nil
+		}
+		// Unreadable or unsupported files are skipped, like fontconfig does.
+		_ = r.AddFile(path)
+		return nil
+	})
+}
+
+// AddFile indexes every face of a font file.
+func (r *FontRegistry) AddFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	data, err = decodeWebFont(data)
+	if err != nil {
+		return err
+	}
+	offsets, err := sfntFaceOffsets(data)
+	if err != nil {
+		return err
+	}
+	var entries []FontEntry
+	for i, off := range offsets {
+		if entry, ok := readFontEntry(data, off); ok {
+			entry.Path, entry.Index = path, i
+			entries = append(entries, entry)
+		}
+	}
+	if len(entries) == 0 {
+		return fmt.Errorf("no usable faces in %s", path)
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.entries = append(r.entries, entries...)
+	return nil
+}
+
+// Entries returns all indexed faces.
+func (r *FontRegistry) Entries() []FontEntry {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([]FontEntry{}, r.entries...)
+}
+
+// Match finds the face which best matches a font pattern.
+//
+// Like fontconfig, Match always returns a face when the registry is not
+// empty: if no family matches, the closest style among all faces is used.
+// Check the Family of the result to detect such substitutions.
+func (r *FontRegistry) Match(pattern string) (FontEntry, error) {
+	p, err := ParseFontPattern(pattern)
+	if err != nil {
+		return FontEntry{}, err
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if len(r.entries) == 0 {
+		return FontEntry{}, errors.New("font registry is empty")
+	}
+
+	candidates := r.entries
+	for _, family := range p.Families {
+		var matches []FontEntry
+		for _, e := range r.entries {
+			if e.matchesName(family) {
+				matches = append(matches, e)
+			}
+		}
+		if len(matches) > 0 {
+			candidates = matches
+			break
+		}
+	}
+
+	weight, slant := WeightRegular, SlantRoman
+	if p.Style != "" {
+		weight, slant = styleWeightSlant(p.Style)
+	}
+	if p.Weight != 0 {
+		weight = p.Weight
+	}
+	if p.Slant != SlantAny {
+		slant = p.Slant
+	}
+
+	best := 0
+	bestScore := [3]int{}
+	for i, e := range candidates {
+		var score [3]int
+		if p.Style != "" && !strings.EqualFold(e.Style, p.Style) {
+			score[0] = 1
+		}
+		if e.Italic != (slant == SlantItalic) {
+			score[1] = 1
+		}
+		score[2] = e.Weight - weight
+		if score[2] < 0 {
+			// Prefer heavier faces over lighter ones at equal distance.
+			score[2] = 1 - 2*score[2]
+		} else {
+			score[2] *= 2
+		}
+		if i == 0 || lessScore(score, bestScore) {
+			best, bestScore = i, score
+		}
+	}
+	return candidates[best], nil
+}
+
+func lessScore(a, b [3]int) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return a[i] < b[i]
+		}
+	}
+	return false
+}
+
+// Lookup resolves a font pattern, such as "Liberation Sans:style=Regular",
+// to a parsed font. See Match for details on matching.
+//
+// Parsed fonts are cached, so repeated lookups return the same font.
+func (r *FontRegistry) Lookup(pattern string) (*ParsedFont, error) {
+	entry, err := r.Match(pattern)
+	if err != nil {
+		return nil, err
+	}
+	key := fontKey{path: entry.Path, index: entry.Index}
+
+	r.lock.Lock()
+	font, ok := r.fonts[key]
+	r.lock.Unlock()
+	if ok {
+		return font, nil
+	}
+
+	data, err := os.ReadFile(entry.Path)
+	if err != nil {
+		return nil, err
+	}
+	font, err = ParseTTC(data, entry.Index)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", entry.Path, err)
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if existing, ok := r.fonts[key]; ok {
+		return existing, nil
+	}
+	r.fonts[key] = font
+	return font, nil
+}
+
+func (e *FontEntry) matchesName(name string) bool {
+	name = normalizeFontName(name)
+	for _, n := range e.Names {
+		if normalizeFontName(n) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeFontName lowercases a name and removes blanks, matching how
+// fontconfig compares family names.
+func normalizeFontName(name string) string {
+	return strings.ToLower(strings.ReplaceAll(name, " ", ""))
+}
+
+func isFontFileName(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".ttf", ".otf", ".ttc", ".otc", ".woff", ".woff2":
+		return true
+	}
+	return false
+}
+
+// readFontEntry reads the names, weight and slant of a face.
+func readFontEntry(data []byte, dirOffset int) (FontEntry, bool) {
+	table, ok := findSFNTTable(data, dirOffset, "name")
+	if !ok {
+		return FontEntry{}, false
+	}
+	names := parseNameTable(table)
+	entry := FontEntry{
+		Family: firstNonEmpty(names[nameIDTypographicFamily], names[nameIDFamily]),
+		Style:  firstNonEmpty(names[nameIDTypographicSubfamily], names[nameIDSubfamily]),
+	}
+	if entry.Family == "" {
+		return FontEntry{}, false
+	}
+	for _, id := range []uint16{nameIDFamily, nameIDTypographicFamily, nameIDFullName, nameIDPostScriptName} {
+		if n := names[id]; n != "" {
+			entry.Names = append(entry.Names, n)
+		}
+	}
+
+	weight, slant := styleWeightSlant(entry.Style)
+	entry.Weight, entry.Italic = weight, slant == SlantItalic
+	if os2, ok := findSFNTTable(data, dirOffset, "OS/2"); ok && len(os2) >= 64 {
+		if w := int(binary.BigEndian.Uint16(os2[4:6])); w > 0 {
+			entry.Weight = w
+		}
+		const fsSelectionItalic, fsSelectionOblique = 1 << 0, 1 << 9
+		fsSelection := binary.BigEndian.Uint16(os2[62:64])
+		entry.Italic = entry.Italic || fsSelection&(fsSelectionItalic|fsSelectionOblique) != 0
+	}
+	return entry, true
+}
@@ -0,0 +1,113 @@
+package textcurve
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseFontPattern(t *testing.T) {
+	cases := map[string]FontPattern{
+		"Liberation Sans:style=Bold Italic": {
+			Families: []string{"Liberation Sans"},
+			Style:    "Bold Italic",
+		},
+		"DejaVu Sans, Arial:bold:italic": {
+			Families: []string{"DejaVu Sans", "Arial"},
+			Weight:   WeightBold,
+			Slant:    SlantItalic,
+		},
+		`Odd\:Name:weight=50:slant=roman:size=10`: {
+			Families: []string{"Odd:Name"},
+			Weight:   WeightLight,
+			Slant:    SlantRoman,
+		},
+		"Sans:weight=200:slant=100": {
+			Families: []string{"Sans"},
+			Weight:   WeightBold,
+			Slant:    SlantItalic,
+		},
+		"Sans:weight=80:slant=0": {
+			Families: []string{"Sans"},
+			Weight:   WeightRegular,
+			Slant:    SlantRoman,
+		},
+		"Sans:weight=0:slant=110": {
+			Families: []string{"Sans"},
+			Weight:   WeightThin,
+			Slant:    SlantItalic,
+		},
+		"Sans:weight=140": {
+			Families: []string{"Sans"},
+			Weight:   550,
+		},
+		"Sans:weight=215": {
+			Families: []string{"Sans"},
+			Weight:   1000,
+		},
+	}
+	for pattern, expected := range cases {
+		actual, err := ParseFontPattern(pattern)
+		if err != nil {
+			t.Fatalf("%q: %v", pattern, err)
+		}
+		if !reflect.DeepEqual(*actual, expected) {
+			t.Errorf("%q: expected %+v but got %+v", pattern, expected, *actual)
+		}
+	}
+	for _, pattern := range []string{
+		"Sans:weight=heavyish", "Sans:weight=400", "Sans:weight=-1", "Sans:slant=50", "Sans:wobbly",
+	} {
+		if _, err := ParseFontPattern(pattern); err == nil {
+			t.Errorf("%q: expected error", pattern)
+		}
+	}
+}
+
+func TestFontRegistry(t *testing.T) {
+	t.Setenv(OpenSCADFontPathEnv, "test_data")
+	r, err := NewFontRegistry()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.AddEnvPath(OpenSCADFontPathEnv); err != nil {
+		t.Fatal(err)
+	}
+	entries := r.Entries()
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries but got %d", len(entries))
+	}
+	var entry FontEntry
+	for _, e := range entries {
+		if e.Family != "CFFTest" {
+			entry = e
+		}
+	}
+	if entry.Family != "Liberation Sans" || entry.Style != "Regular" ||
+		entry.Weight != WeightRegular || entry.Italic {
+		t.Errorf("unexpected entry: %+v", entry)
+	}
+
+	font, err := r.Lookup("Liberation Sans:style=Regular")
+	if err != nil {
+		t.Fatal(err)
+	}
+	font2, err := r.Lookup("liberationsans:bold:italic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if font != font2 {
+		t.Error("lookups should share the cached font")
+	}
+	if _, err := TextOutlines(font, "Hi", Options{Size: 10}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Unknown families fall back to the closest face.
+	match, err := r.Match("No Such Font")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if match.Path != entry.Path {
+		t.Errorf("unexpected fallback: %+v", match)
+	}
+}
@@ -0,0 +1,76 @@
+package textcurve
+
+import (
+	"fmt"
+
+	"github.com/go-text/typesetting/language"
+	textlanguage "golang.org/x/text/language"
+)
+
+// parseScript parses an ISO 15924 script code, such as "Deva" or "Thai".
+// An empty code returns 0, which means that scripts are detected from the
+// text.
+func parseScript(code string) (language.Script, error) {
+	if code == "" {
+		return 0, nil
+	}
+	if len(code) != 4 {
+		return 0, fmt.Errorf("invalid script %q: expected a four letter ISO 15924 code", code)
+	}
+	for _, c := range code {
+		if (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') {
+			return 0, fmt.Errorf("invalid script %q: expected a four letter ISO 15924 code", code)
+		}
+	}
+	return language.ParseScript(code)
+}
+
+// parseLanguage parses a BCP 47 language tag, such as "sr" or "tr-TR".
+// An empty tag leaves the language unset.
+func parseLanguage(tag string) (language.Language, error) {
+	if tag == "" {
+		return "", nil
+	}
+	if _, err := textlanguage.Parse(tag); err != nil {
+		return "", fmt.Errorf("invalid language %q: %w", tag, err)
+	}
+	return language.NewLanguage(tag), nil
+}
+
+// scriptRun is a range of runes written in one script.
+type scriptRun struct {
+	start, end int
+	script     language.Script
+}
+
+// itemizeScripts splits runes into runs of the same script (UAX #24).
+//
+// Runes of the Common and Inherited scripts, such as spaces, punctuation and
+// combining marks, join the run before them, or the first run when they
+// start the text. Text without any specific script is a single Common run.
+func itemizeScripts(runes []rune) []scriptRun {
+	var res []scriptRun
+	for i, r := range runes {
+		script := language.LookupScript(r)
+		n := len(res)
+		if isNeutralScript(script) {
+			if n == 0 {
+				res = append(res, scriptRun{start: i, end: i + 1, script: language.Common})
+			} else {
+				res[n-1].end = i + 1
+			}
+			continue
+		}
+		if n > 0 && (res[n-1].script == script || res[n-1].script == language.Common) {
+			res[n-1].script = script
+			res[n-1].end = i + 1
+		} else {
+			res = append(res, scriptRun{start: i, end: i + 1, script: script})
+		}
+	}
+	return res
+}
+
+func isNeutralScript(s language.Script) bool {
+	return s == language.Common || s == language.Inherited || s == language.Unknown
+}
@@ -0,0 +1,65 @@
+package textcurve
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/go-text/typesetting/language"
+)
+
+func TestItemizeScripts(t *testing.T) {
+	cases := []struct {
+		text     string
+		expected []scriptRun
+	}{
+		{"", nil},
+		{"123 !", []scriptRun{{0, 5, language.Common}}},
+		{"abc", []scriptRun{{0, 3, language.Latin}}},
+		{"(abc) ", []scriptRun{{0, 6, language.Latin}}},
+		{"ab αβ", []scriptRun{{0, 3, language.Latin}, {3, 5, language.Greek}}},
+		{"नमस्ते, abc", []scriptRun{{0, 8, language.Devanagari}, {8, 11, language.Latin}}},
+		{"á б", []scriptRun{{0, 3, language.Latin}, {3, 4, language.Cyrillic}}},
+	}
+	for _, c := range cases {
+		actual := itemizeScripts([]rune(c.text))
+		if !reflect.DeepEqual(actual, c.expected) {
+			t.Errorf("%q: expected %v but got %v", c.text, c.expected, actual)
+		}
+	}
+}
+
+func TestScriptLanguageOptions(t *testing.T) {
+	font := parseTestFont(t)
+
+	for _, script := range []string{"Latn", "latn", "Deva"} {
+		if _, err := TextOutlines(font, "abc", Options{Size: 10, Script: script}); err != nil {
+			t.Errorf("script %q: %v", script, err)
+		}
+	}
+	for _, script := range []string{"Lat", "Latin", "La1n"} {
+		if _, err := TextOutlines(font, "abc", Options{Size: 10, Script: script}); err == nil {
+			t.Errorf("script %q: expected an error", script)
+		}
+	}
+	for _, lang := range []string{"tr", "sr-Latn", "nl_NL", "zh-Hant-TW"} {
+		if _, err := TextOutlines(font, "abc", Options{Size: 10, Language: lang}); err != nil {
+			t.Errorf("language %q: %v", lang, err)
+		}
+	}
+	for _, lang := range []string{"not a language", "x"} {
+		if _, err := TextOutlines(font, "abc", Options{Size: 10, Language: lang}); err == nil {
+			t.Errorf("language %q: expected an error", lang)
+		}
+	}
+
+	// Forcing the script of plain Latin text should not change it.
+	expected, err := TextOutlines(font, "Hello, world", Options{Size: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual, err := TextOutlines(font, "Hello, world", Options{Size: 10, Script: "Latn", Language: "en"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertSameBounds(t, "Latn", actual, expected, 0)
+}
@@ -0,0 +1,252 @@
+package textcurve
+
+import (
+	"encoding/binary"
+	"errors"
+	"sort"
+	"unicode/utf16"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+const (
+	nameIDFamily               = 1
+	nameIDSubfamily            = 2
+	nameIDFullName             = 4
+	nameIDPostScriptName       = 6
+	nameIDTypographicFamily    = 16
+	nameIDTypographicSubfamily = 17
+)
+
+// sfntFaceOffsets returns the offset of the table directory of every face
+// in an sfnt file. Single fonts have one directory at offset 0, while
+// collections (.ttc/.otc) list one directory per face in their header.
+func sfntFaceOffsets(data []byte) ([]int, error) {
+	if len(data) < 12 {
+		return nil, errors.New("font data is too short")
+	}
+	if string(data[:4]) != "ttcf" {
+		return []int{0}, nil
+	}
+	numFonts := int(binary.BigEndian.Uint32(data[8:12]))
+	if numFonts <= 0 || (len(data)-12)/4 < numFonts {
+		return nil, errors.New("bad font collection header")
+	}
+	offsets := make([]int, numFonts)
+	for i := range offsets {
+		off := int(binary.BigEndian.Uint32(data[12+i*4:]))
+		if off <= 0 || off+12 > len(data) {
+			return nil, errors.New("bad font collection offset")
+		}
+		offsets[i] = off
+	}
+	return offsets, nil
+}
+
+// findSFNTTable returns the raw contents of the table with the given tag
+// from the table directory at dirOffset.
+//
+// Table offsets are relative to the start of the file, even for faces
+// inside a collection.
+func findSFNTTable(data []byte, dirOffset int, tag string) ([]byte, bool) {
+	const (
+		tableDirSize = 12
+		recordSize   = 16
+	)
+	if dirOffset < 0 || len(data) < dirOffset+tableDirSize {
+		return nil, false
+	}
+	numTables := int(binary.BigEndian.Uint16(data[dirOffset+4 : dirOffset+6]))
+	recordsOffset := dirOffset + tableDirSize
+	if len(data) < recordsOffset+numTables*recordSize {
+		return nil, false
+	}
+	for i := 0; i < numTables; i++ {
+		recOff := recordsOffset + i*recordSize
+		if string(data[recOff:recOff+4]) != tag {
+			continue
+		}
+		tableOffset := int(binary.BigEndian.Uint32(data[recOff+8 : recOff+12]))
+		tableLen := int(binary.BigEndian.Uint32(data[recOff+12 : recOff+16]))
+		if tableOffset < 0 || tableLen < 0 || tableOffset+tableLen > len(data) {
+			return nil, false
+		}
+		return data[tableOffset : tableOffset+tableLen], true
+	}
+	return nil, false
+}
+
+func parseOS2TypoAscender(data []byte, dirOffset int) (float64, bool) {
+	const typoAscOffset = 68
+	table, ok := findSFNTTable(data, dirOffset, "OS/2")
+	if !ok || len(table) < typoAscOffset+2 {
+		return 0, false
+	}
+	raw := int16(binary.BigEndian.Uint16(table[typoAscOffset : typoAscOffset+2]))
+	return float64(raw), raw > 0
+}
+
+// hasCFFOutlines reports whether the face stores PostScript outlines.
+func hasCFFOutlines(data []byte, dirOffset int) bool {
+	if _, ok := findSFNTTable(data, dirOffset, "CFF "); ok {
+		return true
+	}
+	_, ok := findSFNTTable(data, dirOffset, "CFF2")
+	return ok
+}
+
+// parseNameTable decodes the strings of a 'name' table, keyed by name ID.
+//
+// When a name is stored several times, English Windows records are
+// preferred, followed by other Unicode records and finally Macintosh Roman.
+func parseNameTable(table []byte) map[uint16]string {
+	if len(table) < 6 {
+		return nil
+	}
+	count := int(binary.BigEndian.Uint16(table[2:4]))
+	storage := int(binary.BigEndian.Uint16(table[4:6]))
+	if len(table) < 6+count*12 {
+		return nil
+	}
+
+	res := map[uint16]string{}
+	ranks := map[uint16]int{}
+	for i := 0; i < count; i++ {
+		rec := table[6+i*12:]
+		platformID := binary.BigEndian.Uint16(rec[0:2])
+		encodingID := binary.BigEndian.Uint16(rec[2:4])
+		languageID := binary.BigEndian.Uint16(rec[4:6])
+		nameID := binary.BigEndian.Uint16(rec[6:8])
+		length := int(binary.BigEndian.Uint16(rec[8:10]))
+		offset := storage + int(binary.BigEndian.Uint16(rec[10:12]))
+		if offset+length > len(table) {
+			continue
+		}
+		raw := table[offset : offset+length]
+
+		var rank int
+		var value string
+		switch {
+		case platformID == 3 && (encodingID == 1 || encodingID == 10):
+			rank = 2
+			if languageID == 0x409 {
+				rank = 3
+			}
+			value = decodeUTF16BE(raw)
+		case platformID == 0:
+			rank = 1
+			value = decodeUTF16BE(raw)
+		case platformID == 1 && encodingID == 0 && languageID == 0:
+			decoded, err := charmap.Macintosh.NewDecoder().Bytes(raw)
+			if err != nil {
+				continue
+			}
+			value = string(decoded)
+		default:
+			continue
+		}
+		if prev, ok := ranks[nameID]; ok && prev >= rank {
+			continue
+		}
+		ranks[nameID] = rank
+		res[nameID] = value
+	}
+	return res
+}
+
+func decodeUTF16BE(raw []byte) string {
+	units := make([]uint16, len(raw)/2)
+	for i := range units {
+		units[i] = binary.BigEndian.Uint16(raw[i*2:])
+	}
+	return string(utf16.Decode(units))
+}
+
+type sfntTable struct {
+	tag  string
+	data []byte
+}
+
+type sfntFace struct {
+	flavor uint32
+	tables []int // indices into the shared table list
+}
+
+// writeSFNT assembles a font file from decoded tables. When collection is
+// set, the result is a TrueType collection whose faces may share tables.
+func writeSFNT(faces []sfntFace, tables []sfntTable, collection bool) []byte {
+	pad4 := func(n int) int { return (n + 3) &^ 3 }
+
+	size := 0
+	if collection {
+		size = 12 + 4*len(faces)
+	}
+	dirOffsets := make([]int, len(faces))
+	for i, face := range faces {
+		dirOffsets[i] = size
+		size += 12 + 16*len(face.tables)
+	}
+	tableOffsets := make([]int, len(tables))
+	for i, t := range tables {
+		tableOffsets[i] = size
+		size += pad4(len(t.data))
+	}
+
+	res := make([]byte, size)
+	if collection {
+		copy(res, "ttcf")
+		binary.BigEndian.PutUint32(res[4:], 0x00010000)
+		binary.BigEndian.PutUint32(res[8:], uint32(len(faces)))
+		for i, off := range dirOffsets {
+			binary.BigEndian.PutUint32(res[12+4*i:], uint32(off))
+		}
+	}
+	for i, t := range tables {
+		copy(res[tableOffsets[i]:], t.data)
+	}
+
+	for i, face := range faces {
+		indices := append([]int{}, face.tables...)
+		sort.Slice(indices, func(a, b int) bool {
+			return tables[indices[a]].tag < tables[indices[b]].tag
+		})
+
+		dir := res[dirOffsets[i]:]
+		numTables := len(indices)
+		entrySelector := 0
+		for 2<<entrySelector <= numTables {
+			entrySelector++
+		}
+		searchRange := 16 << entrySelector
+		binary.BigEndian.PutUint32(dir[0:], face.flavor)
+		binary.BigEndian.PutUint16(dir[4:], uint16(numTables))
+		binary.BigEndian.PutUint16(dir[6:], uint16(searchRange))
+		binary.BigEndian.PutUint16(dir[8:], uint16(entrySelector))
+		binary.BigEndian.PutUint16(dir[10:], uint16(numTables*16-searchRange))
+		for j, idx := range indices {
+			rec := dir[12+16*j:]
+			t := tables[idx]
+			copy(rec[0:4], t.tag)
+			binary.BigEndian.PutUint32(rec[4:], sfntChecksum(res[tableOffsets[idx]:tableOffsets[idx]+pad4(len(t.data))]))
+			binary.BigEndian.PutUint32(rec[8:], uint32(tableOffsets[idx]))
+			binary.BigEndian.PutUint32(rec[12:], uint32(len(t.data)))
+		}
+	}
+	return res
+}
+
+func sfntChecksum(data []byte) uint32 {
+	var sum uint32
+	for i := 0; i+4 <= len(data); i += 4 {
+		sum += binary.BigEndian.Uint32(data[i:])
+	}
+	return sum
+}
+
+func allTableIndices(n int) []int {
+	res := make([]int, n)
+	for i := range res {
+		res[i] = i
+	}
+	return res
+}
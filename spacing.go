@@ -0,0 +1,52 @@
+package textcurve
+
+import "github.com/go-text/typesetting/segmenter"
+
+// applySpacing adds opt.LetterSpacing between the grapheme clusters of a
+// line and opt.WordSpacing after each of its word separators.
+//
+// Letter spacing only separates glyphs of different shaping clusters which
+// start a grapheme cluster, so that ligatures and combining marks stay
+// together, and is not added after the last glyph of the line.
+func (l *layoutLine) applySpacing(runes []rune, opt Options) {
+	if (opt.LetterSpacing == 0 && opt.WordSpacing == 0) || len(l.glyphs) == 0 {
+		return
+	}
+
+	var seg segmenter.Segmenter
+	seg.Init(runes)
+	graphemeStarts := map[int]bool{}
+	graphemes := seg.GraphemeIterator()
+	for graphemes.Next() {
+		graphemeStarts[graphemes.Grapheme().Offset] = true
+	}
+
+	extra := make([]float64, len(l.glyphs))
+	for i, g := range l.glyphs {
+		clusterEnd := i+1 == len(l.glyphs) || l.glyphs[i+1].cluster != g.cluster
+		if clusterEnd && i+1 < len(l.glyphs) && graphemeStarts[max(g.cluster, l.glyphs[i+1].cluster)] {
+			extra[i] += opt.LetterSpacing
+		}
+		if clusterEnd && l.isSeparator(i, runes) {
+			extra[i] += opt.WordSpacing
+		}
+	}
+	l.addAdvances(extra, opt.Vertical)
+}
+
+// addAdvances widens each glyph of a line by extra[i] in model units, and
+// moves the glyphs after it along the line to make room.
+func (l *layoutLine) addAdvances(extra []float64, vertical bool) {
+	shift := 0.0
+	for i := range l.glyphs {
+		g := &l.glyphs[i]
+		if vertical {
+			g.penY -= shift / g.scale
+		} else {
+			g.penX += shift / g.scale
+		}
+		g.advance += extra[i]
+		shift += extra[i]
+	}
+	l.advance += shift
+}
@@ -0,0 +1,63 @@
+package textcurve
+
+import (
+	"math"
+	"testing"
+)
+
+func TestLetterWordSpacing(t *testing.T) {
+	font := parseTestFont(t)
+
+	glyphs := func(text string, opt Options) []Glyph {
+		res, err := TextGlyphs(font, text, opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	for _, size := range []float64{10, 20} {
+		for _, c := range []struct {
+			text    string
+			letter  float64
+			word    float64
+			offsets []float64
+		}{
+			{"abc", 2, 0, []float64{0, 2, 4}},
+			{"abc", -0.5, 0, []float64{0, -0.5, -1}},
+			{"a b", 0, 3, []float64{0, 0, 3}},
+			{"a b", 1, 3, []float64{0, 1, 5}},
+			// Marks stay on their base.
+			{"x\u0301b", 2, 0, []float64{0, 0, 2}},
+		} {
+			plain := glyphs(c.text, Options{Size: size})
+			spaced := glyphs(c.text, Options{Size: size, LetterSpacing: c.letter, WordSpacing: c.word})
+			if len(spaced) != len(c.offsets) || len(plain) != len(c.offsets) {
+				t.Fatalf("%q: expected %d glyphs but got %d", c.text, len(c.offsets), len(spaced))
+			}
+			for i, offset := range c.offsets {
+				if actual := spaced[i].Pen.X - plain[i].Pen.X; math.Abs(actual-offset) > 1e-8 {
+					t.Errorf("%q size %f: glyph %d moved by %f instead of %f", c.text, size, i, actual, offset)
+				}
+			}
+			last := len(c.offsets) - 1
+			if spaced[last].Advance != plain[last].Advance {
+				t.Errorf("%q: last glyph advance changed", c.text)
+			}
+		}
+	}
+
+	// Spacing is taken into account by wrapping and alignment.
+	opt := Options{Size: 10, LetterSpacing: 1, Align: Align{HAlign: HAlignRight}}
+	spaced := glyphs("ab", opt)
+	last := spaced[len(spaced)-1]
+	if end := last.Pen.X + last.Advance; math.Abs(end) > 1e-8 {
+		t.Errorf("expected right-aligned line to end at 0 but got %f", end)
+	}
+	plain := glyphs("ab ab", Options{Size: 10})
+	width := plain[4].Pen.X + plain[4].Advance
+	wrapped := glyphs("ab ab", Options{Size: 10, LetterSpacing: 1, MaxWidth: width + 1})
+	if wrapped[len(wrapped)-1].Line != 1 {
+		t.Error("expected letter spacing to wrap the text")
+	}
+}
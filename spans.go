@@ -0,0 +1,105 @@
+package textcurve
+
+import (
+	"errors"
+)
+
+// Span is a range of rich text with its own font and style.
+type Span struct {
+	Text string
+
+	// Font draws the span, falling back to Fallbacks for missing glyphs.
+	Font      *ParsedFont
+	Fallbacks []*ParsedFont
+
+	// Size is like Options.Size; 0 uses Options.Size.
+	Size float64
+
+	// Spacing and Kerning are like the fields of Options, but only apply
+	// to this span. A Spacing of 0 or a nil Kerning uses Options.
+	Spacing float64
+	Kerning *bool
+
+	// Features are added to Options.Features for this span, with ranges
+	// relative to the start of the span.
+	Features []Feature
+
+	// BaselineShift raises the span above the baseline by this many model
+	// units, e.g. for superscripts, or lowers it when negative.
+	BaselineShift float64
+}
+
+// TextOutlinesSpans lays out a sequence of spans like TextOutlines, sharing
+// baselines between spans so that the whole text is aligned as one block.
+//
+// Spans may contain line breaks, and each line is as tall as the tallest
+// span on it. Options.Size, Spacing, Kerning and Features provide defaults
+// for the spans, and the other options apply to the whole text.
+func TextOutlinesSpans(spans []Span, opt Options) (Outlines, error) {
+	layout, err := layoutSpans(spans, opt)
+	if err != nil || layout == nil {
+		return nil, err
+	}
+	return layout.outlines(), nil
+}
+
+func layoutSpans(spans []Span, opt Options) (*textLayout, error) {
+	opt, err := checkOptions(opt)
+	if err != nil {
+		return nil, err
+	}
+	var text []rune
+	var styles []textStyle
+	for _, span := range spans {
+		if span.Font == nil {
+			return nil, errors.New("nil font")
+		}
+		if span.Spacing < 0 {
+			return nil, errors.New("Spacing must be >= 0")
+		}
+		if err := checkFeatures(span.Features); err != nil {
+			return nil, err
+		}
+		runes := []rune(span.Text)
+		if len(runes) == 0 {
+			continue
+		}
+		start, end := len(text), len(text)+len(runes)
+		text = append(text, runes...)
+
+		spanOpt := opt
+		if span.Size != 0 {
+			spanOpt.Size = span.Size
+		}
+		if span.Spacing != 0 {
+			spanOpt.Spacing = span.Spacing
+		}
+		if span.Kerning != nil {
+			spanOpt.Kerning = *span.Kerning
+		}
+		spanOpt.Features = append([]Feature{}, opt.Features...)
+		for _, f := range span.Features {
+			f.Start += start
+			if f.End == 0 {
+				f.End = end
+			} else {
+				f.End = min(f.End+start, end)
+			}
+			if f.Start < f.End {
+				spanOpt.Features = append(spanOpt.Features, f)
+			}
+		}
+
+		fonts := append([]*ParsedFont{span.Font}, span.Fallbacks...)
+		style, err := newTextStyle(fonts, start, end, spanOpt)
+		if err != nil {
+			return nil, err
+		}
+		style.shift = span.BaselineShift
+		styles = append(styles, style)
+	}
+	if len(styles) == 0 {
+		return nil, nil
+	}
+	return layoutStyled(text, styles, opt), nil
+}
@@ -0,0 +1,172 @@
+package textcurve
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/unixpickle/model3d/model2d"
+)
+
+func TestTextOutlinesSpans(t *testing.T) {
+	font := parseTestFont(t)
+
+	t.Run("Single", func(t *testing.T) {
+		// Spans inherit Spacing and Kerning from the options.
+		opt := Options{Size: 10, Spacing: 1.5, Kerning: true,
+			Align: Align{HAlign: HAlignCenter, VAlign: VAlignCenter}}
+		expected, err := TextOutlines(font, "HAVA\nWorld", opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		actual, err := TextOutlinesSpans([]Span{
+			{Text: "H", Font: font},
+			{Text: "AVA\nWo", Font: font},
+			{Text: "rld", Font: font},
+		}, opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertSameBounds(t, "spans", actual, expected, 1e-8)
+	})
+
+	t.Run("Overrides", func(t *testing.T) {
+		expected, err := TextOutlines(font, "HAVA", Options{Size: 10, Spacing: 1.2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		kerning := false
+		actual, err := TextOutlinesSpans([]Span{
+			{Text: "HA", Font: font, Spacing: 1.2, Kerning: &kerning},
+			{Text: "VA", Font: font, Spacing: 1.2, Kerning: &kerning},
+		}, Options{Size: 10, Spacing: 1.5, Kerning: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertSameBounds(t, "spans", actual, expected, 1e-8)
+	})
+
+	t.Run("Baselines", func(t *testing.T) {
+		layout, err := layoutSpans([]Span{
+			{Text: "big", Font: font, Size: 20},
+			{Text: "small", Font: font},
+			{Text: "2", Font: font, Size: 5, BaselineShift: 4},
+			{Text: "\nnext", Font: font},
+		}, Options{Size: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(layout.lines) != 2 {
+			t.Fatalf("expected 2 lines but got %d", len(layout.lines))
+		}
+		line := layout.lines[0]
+		if len(line.glyphs) != 9 {
+			t.Fatalf("expected 9 glyphs but got %d", len(line.glyphs))
+		}
+		for i, g := range line.glyphs {
+			expectedShift := 0.0
+			if i == 8 {
+				expectedShift = 4
+			}
+			if y := g.toModel(model2d.Coord{}).Y; math.Abs(y-expectedShift) > 1e-8 {
+				t.Errorf("glyph %d: expected baseline %f but got %f", i, expectedShift, y)
+			}
+		}
+		if s0, s1 := line.glyphs[0].scale, line.glyphs[3].scale; math.Abs(s0-2*s1) > 1e-8 {
+			t.Errorf("expected double scale but got %f and %f", s0, s1)
+		}
+
+		// The first line is as tall as its largest span.
+		small, err := layoutText([]*ParsedFont{font}, "a\nb", Options{Size: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		gap := line.baseline - layout.lines[1].baseline
+		smallGap := small.lines[0].baseline - small.lines[1].baseline
+		if math.Abs(gap-2*smallGap) > 1e-8 {
+			t.Errorf("expected line gap %f but got %f", 2*smallGap, gap)
+		}
+	})
+
+	t.Run("BlankLine", func(t *testing.T) {
+		// The blank line between the spans uses the style of the span it
+		// starts.
+		layout, err := layoutSpans([]Span{
+			{Text: "BIG\n", Font: font, Size: 20},
+			{Text: "\nsmall", Font: font, Size: 10},
+		}, Options{Size: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(layout.lines) != 3 {
+			t.Fatalf("expected 3 lines but got %d", len(layout.lines))
+		}
+		heights := []float64{layout.lines[0].height, layout.lines[1].height, layout.lines[2].height}
+		if heights[0] != 2*heights[2] || heights[1] != heights[2] {
+			t.Errorf("unexpected line heights: %v", heights)
+		}
+		if m := layout.lines[1].metrics; m != layout.lines[2].metrics {
+			t.Errorf("expected metrics %+v but got %+v", layout.lines[2].metrics, m)
+		}
+
+		styles := []textStyle{{start: 0, end: 4}, {start: 4, end: 10}}
+		for _, c := range []struct {
+			start, end int
+			expected   []textStyle
+		}{
+			{0, 3, styles[:1]},
+			{0, 5, styles},
+			{4, 4, styles[1:]},
+			{3, 3, styles[:1]},
+			{10, 10, styles[1:]},
+		} {
+			if actual := lineStyles(styles, c.start, c.end); !reflect.DeepEqual(actual, c.expected) {
+				t.Errorf("[%d, %d): expected %v but got %v", c.start, c.end, c.expected, actual)
+			}
+		}
+	})
+
+	t.Run("Features", func(t *testing.T) {
+		fontBytes, err := os.ReadFile(filepath.Join("test_data", "LiberationSans-Regular.ttf"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		featureFont, err := ParseTTF(addTestTables(fontBytes, sfntTable{"GSUB", buildTestGSUB("ss01", 68, 69)}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		layout, err := layoutSpans([]Span{
+			{Text: "aa", Font: featureFont},
+			{Text: "aaa", Font: featureFont, Features: []Feature{{Tag: "ss01", Value: 1, Start: 1}}},
+			{Text: "a", Font: featureFont, Features: []Feature{{Tag: "ss01", Value: 1}}},
+		}, Options{Size: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var indices []int
+		for _, g := range layout.lines[0].glyphs {
+			indices = append(indices, int(g.index))
+		}
+		if expected := []int{68, 68, 68, 69, 69, 69}; !reflect.DeepEqual(indices, expected) {
+			t.Errorf("expected %v but got %v", expected, indices)
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		for _, spans := range [][]Span{
+			{{Text: "a"}},
+			{{Text: "a", Font: font, Size: -1}},
+			{{Text: "a", Font: font, Spacing: -1}},
+			{{Text: "a", Font: font, Features: []Feature{{Tag: "ss1"}}}},
+		} {
+			if _, err := TextOutlinesSpans(spans, Options{Size: 10}); err == nil {
+				t.Errorf("expected error for %v", spans)
+			}
+		}
+		if _, err := TextOutlinesSpans([]Span{{Text: "a", Font: font}}, Options{}); err == nil {
+			t.Error("expected error for missing size")
+		}
+	})
+}
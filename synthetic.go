@@ -0,0 +1,115 @@
+package textcurve
+
+import (
+	"math"
+
+	"github.com/unixpickle/model3d/model2d"
+)
+
+// synthesizeStyle applies Options.Embolden and Options.Oblique to the
+// contours of one glyph in place.
+func synthesizeStyle(contours []Contour, opt Options) []Contour {
+	if opt.Embolden != 0 {
+		emboldenContours(contours, opt.Embolden)
+	}
+	if opt.Oblique != 0 {
+		for _, c := range contours {
+			for i := range c {
+				c[i].X += opt.Oblique * c[i].Y
+			}
+		}
+	}
+	return contours
+}
+
+// emboldenContours widens the strokes of a glyph by strength.
+//
+// Like FT_Outline_Embolden, every edge moves outwards by strength/2, and the
+// glyph is then moved right and up by strength/2, so that its left and
+// bottom edges stay in place while it grows by strength in each direction.
+func emboldenContours(contours []Contour, strength float64) {
+	// The fill side is determined by the overall orientation, since
+	// TrueType and (reversed) CFF outlines may both appear here.
+	var area float64
+	for _, c := range contours {
+		area += contourArea(c)
+	}
+	shift := strength / 2
+	if area > 0 {
+		// Counter-clockwise outer contours: the outside is on the right.
+		shift = -shift
+	}
+
+	for ci, c := range contours {
+		closed := len(c) > 1 && c[0] == c[len(c)-1]
+		pts := c
+		if closed {
+			pts = c[:len(c)-1]
+		}
+		n := len(pts)
+		if n < 3 {
+			continue
+		}
+		res := make(Contour, n, len(c))
+		for i, cur := range pts {
+			prev := prevDistinct(pts, i)
+			next := nextDistinct(pts, i)
+			inNormal := leftNormal(cur.Sub(prev))
+			outNormal := leftNormal(next.Sub(cur))
+
+			// Move along the bisector so both edges move by shift,
+			// limiting the miter length at very sharp corners.
+			bisector := inNormal.Add(outNormal)
+			cos := inNormal.Dot(outNormal)
+			offset := model2d.Coord{}
+			if denom := 1 + cos; denom > 1e-8 {
+				offset = bisector.Scale(shift / denom)
+				if maxLen := math.Abs(shift) * 4; offset.Norm() > maxLen {
+					offset = offset.Scale(maxLen / offset.Norm())
+				}
+			}
+			res[i] = cur.Add(offset).Add(model2d.XY(strength/2, strength/2))
+		}
+		if closed {
+			res = append(res, res[0])
+		}
+		contours[ci] = res
+	}
+}
+
+// contourArea returns the signed area of a contour, which is positive for
+// counter-clockwise contours.
+func contourArea(c Contour) float64 {
+	var area float64
+	for i := range c {
+		p1, p2 := c[i], c[(i+1)%len(c)]
+		area += p1.X*p2.Y - p2.X*p1.Y
+	}
+	return area / 2
+}
+
+func leftNormal(v model2d.Coord) model2d.Coord {
+	n := v.Norm()
+	if n == 0 {
+		return model2d.Coord{}
+	}
+	return model2d.XY(-v.Y/n, v.X/n)
+}
+
+func prevDistinct(pts []model2d.Coord, i int) model2d.Coord {
+	for j := 1; j < len(pts); j++ {
+		if p := pts[(i-j+len(pts))%len(pts)]; p != pts[i] {
+			return p
+		}
+	}
+	return pts[i]
+}
+
+func nextDistinct(pts []model2d.Coord, i int) model2d.Coord {
+	for j := 1; j < len(pts); j++ {
+		if p := pts[(i+j)%len(pts)]; p != pts[i] {
+			return p
+		}
+	}
+	return pts[i]
+}
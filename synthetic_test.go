@@ -0,0 +1,68 @@
+package textcurve
+
+import (
+	"math"
+	"testing"
+
+	"github.com/unixpickle/model3d/model2d"
+)
+
+func TestSyntheticStyle(t *testing.T) {
+	font := parseTestFont(t)
+	opt := Options{Size: 10}
+	plain, err := TextOutlines(font, "l", opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plainMin, plainMax := outlinesBounds(plain)
+
+	t.Run("Embolden", func(t *testing.T) {
+		opt := opt
+		opt.Embolden = 0.5
+		bold, err := TextOutlines(font, "l", opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		boldMin, boldMax := outlinesBounds(bold)
+		if boldMin.Dist(plainMin) > 1e-8 {
+			t.Errorf("expected min %v but got %v", plainMin, boldMin)
+		}
+		if expected := plainMax.Add(model2d.XY(0.5, 0.5)); boldMax.Dist(expected) > 1e-8 {
+			t.Errorf("expected max %v but got %v", expected, boldMax)
+		}
+
+		// Each glyph's advance grows by the embolden strength.
+		opt.Align.HAlign = HAlignRight
+		opt.Embolden = 0
+		plainRight, err := TextOutlines(font, "ll", opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		opt.Embolden = 0.5
+		boldRight, err := TextOutlines(font, "ll", opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		min1, _ := outlinesBounds(plainRight)
+		min2, _ := outlinesBounds(boldRight)
+		if math.Abs(min2.X-(min1.X-1)) > 1e-8 {
+			t.Errorf("expected right-aligned min x %f but got %f", min1.X-1, min2.X)
+		}
+	})
+
+	t.Run("Oblique", func(t *testing.T) {
+		opt := opt
+		opt.Oblique = 0.2
+		slanted, err := TextOutlines(font, "l", opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		slantedMin, slantedMax := outlinesBounds(slanted)
+		if math.Abs(slantedMin.X-plainMin.X) > 1e-8 {
+			t.Errorf("expected min x %f but got %f", plainMin.X, slantedMin.X)
+		}
+		if expected := plainMax.X + 0.2*plainMax.Y; math.Abs(slantedMax.X-expected) > 1e-8 {
+			t.Errorf("expected max x %f but got %f", expected, slantedMax.X)
+		}
+	})
+}
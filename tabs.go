@@ -0,0 +1,138 @@
+package textcurve
+
+import (
+	"errors"
+	"math"
+)
+
+// TabAlign controls how the text after a tab is placed at a tab stop.
+type TabAlign int
+
+const (
+	// TabAlignLeft starts the text at the stop.
+	TabAlignLeft TabAlign = iota
+	// TabAlignCenter centers the text on the stop.
+	TabAlignCenter
+	// TabAlignRight ends the text at the stop.
+	TabAlignRight
+	// TabAlignDecimal places the first decimal separator of the text at
+	// the stop, or ends the text there if it has none.
+	TabAlignDecimal
+)
+
+// TabStop is a position along a line where the text after a tab goes.
+type TabStop struct {
+	// Pos is the distance from the start of the line in model units.
+	Pos   float64
+	Align TabAlign
+
+	// Decimal is the separator used by TabAlignDecimal; 0 defaults to '.'.
+	Decimal rune
+}
+
+// checkTabStops validates Options.TabStops.
+func checkTabStops(stops []TabStop) error {
+	for i, stop := range stops {
+		if stop.Pos < 0 {
+			return errors.New("TabStops must have Pos >= 0")
+		}
+		if i > 0 && stop.Pos <= stops[i-1].Pos {
+			return errors.New("TabStops must be in increasing order")
+		}
+		if stop.Align < TabAlignLeft || stop.Align > TabAlignDecimal {
+			return errors.New("unknown TabAlign")
+		}
+	}
+	return nil
+}
+
+// defaultTabWidth returns the width of eight spaces of the primary font of
+// the first style.
+func defaultTabWidth(styles []textStyle) float64 {
+	style := &styles[0]
+	font := style.fonts[0]
+	_, advance := shapeRun(font, []rune{' '}, textRun{end: 1, language: style.language}, style.opt)
+	if advance <= 0 {
+		advance = font.UnitsPerEm() / 4
+	}
+	return 8 * advance * style.emSize / font.UnitsPerEm()
+}
+
+// nextTabStop returns the first stop after the position x, using stops
+// every opt.TabWidth after the explicit ones.
+func nextTabStop(x float64, opt Options) TabStop {
+	for _, stop := range opt.TabStops {
+		if stop.Pos > x {
+			return stop
+		}
+	}
+	return TabStop{Pos: (math.Floor(x/opt.TabWidth) + 1) * opt.TabWidth}
+}
+
+// applyTabs widens the tabs of a line so that the text after each tab is
+// aligned to the next tab stop.
+//
+// Tabs are shaped as spaces. Stops are measured from the start of the line
+// in the paragraph direction, and text which does not fit between its tab
+// and stop keeps the tab as wide as a space.
+func (l *layoutLine) applyTabs(runes []rune, opt Options) {
+	isTab := func(i int) bool {
+		c := l.glyphs[i].cluster
+		return c >= 0 && c < len(runes) && runes[c] == '\t'
+	}
+
+	// Walk the glyphs from the start of the line, computing the extra
+	// advance of each tab.
+	order := make([]int, len(l.glyphs))
+	for i := range order {
+		order[i] = i
+		if l.rtl {
+			order[i] = len(l.glyphs) - 1 - i
+		}
+	}
+	extra := make([]float64, len(l.glyphs))
+	x := 0.0
+	for i := 0; i < len(order); i++ {
+		if !isTab(order[i]) {
+			x += l.glyphs[order[i]].advance
+			continue
+		}
+		tabStart := x
+		x += l.glyphs[order[i]].advance
+		stop := nextTabStop(tabStart, opt)
+		decimal := stop.Decimal
+		if decimal == 0 {
+			decimal = '.'
+		}
+
+		// Measure the text up to the next tab.
+		width, decimalPos := 0.0, -1.0
+		for j := i + 1; j < len(order) && !isTab(order[j]); j++ {
+			g := &l.glyphs[order[j]]
+			if decimalPos < 0 && g.cluster >= 0 && g.cluster < len(runes) && runes[g.cluster] == decimal {
+				decimalPos = width
+			}
+			width += g.advance
+		}
+		var segStart float64
+		switch stop.Align {
+		case TabAlignLeft:
+			segStart = stop.Pos
+		case TabAlignCenter:
+			segStart = stop.Pos - width/2
+		case TabAlignRight:
+			segStart = stop.Pos - width
+		case TabAlignDecimal:
+			if decimalPos < 0 {
+				decimalPos = width
+			}
+			segStart = stop.Pos - decimalPos
+		}
+		if segStart >= tabStart {
+			extra[order[i]] = segStart - x
+			x = segStart
+		}
+	}
+
+	l.addAdvances(extra, opt.Vertical)
+}
@@ -0,0 +1,116 @@
+package textcurve
+
+import (
+	"math"
+	"testing"
+)
+
+func TestTabStops(t *testing.T) {
+	font := parseTestFont(t)
+
+	// pens returns the pen x of the glyph of each rune in runes.
+	pens := func(text string, opt Options, runes string) []float64 {
+		opt.Size = 10
+		glyphs, err := TextGlyphs(font, text, opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		textRunes := []rune(text)
+		var res []float64
+		for _, r := range runes {
+			for _, g := range glyphs {
+				if textRunes[g.Start] == r {
+					res = append(res, g.Pen.X)
+					break
+				}
+			}
+		}
+		return res
+	}
+	assertPens := func(name string, actual, expected []float64) {
+		if len(actual) != len(expected) {
+			t.Fatalf("%s: expected %d pens but got %d", name, len(expected), len(actual))
+		}
+		for i, x := range expected {
+			if math.Abs(actual[i]-x) > 1e-8 {
+				t.Errorf("%s: expected pen %d at %f but got %f", name, i, x, actual[i])
+			}
+		}
+	}
+
+	space, err := TextGlyphs(font, " ", Options{Size: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tabWidth := 8 * space[0].Advance
+	assertPens("default", pens("a\tb\tc", Options{}, "bc"), []float64{tabWidth, 2 * tabWidth})
+	assertPens("width", pens("a\tb\tc", Options{TabWidth: 3}, "bc"), []float64{9, 18})
+
+	ab, err := TextGlyphs(font, "ab", Options{Size: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	aWidth, bWidth := ab[0].Advance, ab[1].Advance
+
+	stops := []TabStop{
+		{Pos: 20, Align: TabAlignLeft},
+		{Pos: 40, Align: TabAlignCenter},
+		{Pos: 60, Align: TabAlignRight},
+	}
+	assertPens("stops", pens("a\tb\tb\tb\tb", Options{TabStops: stops, TabWidth: 100}, "b"),
+		[]float64{20})
+	glyphs, err := TextGlyphs(font, "a\tb\tb\tb\tb", Options{Size: 10, TabStops: stops, TabWidth: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var bPens []float64
+	for _, g := range glyphs {
+		if g.Index == int(ab[1].Index) {
+			bPens = append(bPens, g.Pen.X)
+		}
+	}
+	assertPens("aligned stops", bPens, []float64{20, 40 - bWidth/2, 60 - bWidth, 100})
+
+	// Text which does not fit before a stop follows the tab.
+	overflow := []TabStop{{Pos: 4*aWidth + 1, Align: TabAlignRight}}
+	assertPens("overflow", pens("aaaa\tbb", Options{TabStops: overflow}, "b"),
+		[]float64{4*aWidth + space[0].Advance})
+
+	decimal := []TabStop{{Pos: 30, Align: TabAlignDecimal}}
+	for _, text := range []string{"\t12.5", "\t3.25", "\t100.125"} {
+		assertPens(text, pens(text, Options{TabStops: decimal}, "."), []float64{30})
+	}
+	comma := []TabStop{{Pos: 30, Align: TabAlignDecimal, Decimal: ','}}
+	assertPens("comma", pens("\t3,25", Options{TabStops: comma}, ","), []float64{30})
+	noDecimal, err := TextGlyphs(font, "\t12", Options{Size: 10, TabStops: decimal})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if end := noDecimal[2].Pen.X + noDecimal[2].Advance; math.Abs(end-30) > 1e-8 {
+		t.Errorf("expected number without decimal to end at 30 but got %f", end)
+	}
+
+	// Tabs are drawn as spaces.
+	withTab, err := TextOutlines(font, "a\tb", Options{Size: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	withoutTab, err := TextOutlines(font, "ab", Options{Size: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(withTab) != len(withoutTab) {
+		t.Errorf("expected %d contours but got %d", len(withoutTab), len(withTab))
+	}
+
+	for _, opt := range []Options{
+		{Size: 10, TabWidth: -1},
+		{Size: 10, TabStops: []TabStop{{Pos: -1}}},
+		{Size: 10, TabStops: []TabStop{{Pos: 2}, {Pos: 1}}},
+		{Size: 10, TabStops: []TabStop{{Pos: 2, Align: 10}}},
+	} {
+		if _, err := TextOutlines(font, "a\tb", opt); err == nil {
+			t.Errorf("expected error for %v", opt.TabStops)
+		}
+	}
+}
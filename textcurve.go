@@ -1,14 +1,17 @@
 package textcurve
 
 import (
-	"bytes"
-	"encoding/binary"
 	"errors"
+	"image/color"
 	"math"
+	"slices"
+	"sync"
 
 	"github.com/go-text/typesetting/di"
 	gotextfont "github.com/go-text/typesetting/font"
 	ot "github.com/go-text/typesetting/font/opentype"
+	"github.com/go-text/typesetting/font/opentype/tables"
+	"github.com/go-text/typesetting/language"
 	"github.com/go-text/typesetting/shaping"
 	"github.com/golang/freetype/truetype"
 	"github.com/unixpickle/model3d/model2d"
@@ -31,6 +34,12 @@ type Outlines []Contour
 //   - HAlignLeft: align to the text origin on the left.
 //   - HAlignCenter: center around the text origin.
 //   - HAlignRight: align to the text advance on the right.
+//   - HAlignStart: HAlignLeft for LTR paragraphs, HAlignRight for RTL ones.
+//   - HAlignEnd: HAlignRight for LTR paragraphs, HAlignLeft for RTL ones.
+//   - HAlignJustify: stretch wrapped lines to Options.MaxWidth.
+//
+// By default, left and right alignment use the pen start and advance of
+// each line, while centering uses its outline bounds; see HAlignMode.
 type HAlign int
 
 const (
@@ -40,6 +49,14 @@ const (
 	HAlignCenter
 	// HAlignRight aligns text to the right of the anchor point.
 	HAlignRight
+	// HAlignStart aligns each line at the start of its paragraph direction.
+	HAlignStart
+	// HAlignEnd aligns each line at the end of its paragraph direction.
+	HAlignEnd
+	// HAlignJustify widens the gaps between words so that every wrapped
+	// line spans Options.MaxWidth, except for the last line of each
+	// paragraph, which is aligned like HAlignStart.
+	HAlignJustify
 )
 
 // VAlign controls vertical text alignment.
@@ -49,6 +66,15 @@ const (
 //   - VAlignTop: align the top bound to y=0.
 //   - VAlignCenter: center vertically around y=0.
 //   - VAlignBottom: align the bottom bound to y=0.
+//   - VAlignAscender: align the first line's ascender to y=0.
+//   - VAlignCapHeight: align the first line's cap height to y=0.
+//   - VAlignXHeight: align the first line's x-height to y=0.
+//   - VAlignDescender: align the last line's descender to y=0.
+//   - VAlignEmCenter: center the em boxes of the lines around y=0.
+//
+// The bound-based modes depend on the outlines of the text, so that "ag"
+// and "Aa" are placed differently. The other modes only depend on the font
+// metrics, and keep the baselines of different labels in line.
 type VAlign int
 
 const (
@@ -60,55 +86,387 @@ const (
 	VAlignCenter
 	// VAlignBottom aligns text to the bottom bound.
 	VAlignBottom
+	// VAlignAscender aligns the ascender of the first line, as used for
+	// its line height, to the anchor point.
+	VAlignAscender
+	// VAlignCapHeight aligns the top of the first line's capital letters
+	// to the anchor point.
+	VAlignCapHeight
+	// VAlignXHeight aligns the top of the first line's lowercase letters
+	// to the anchor point.
+	VAlignXHeight
+	// VAlignDescender aligns the descender of the last line to the anchor
+	// point.
+	VAlignDescender
+	// VAlignEmCenter centers text vertically between the top of the first
+	// line's em box and the bottom of the last one's. Em boxes are one em
+	// tall, and split between the ascender and descender in proportion.
+	VAlignEmCenter
+)
+
+// HAlignMode selects which extent of a line HAlign aligns.
+type HAlignMode int
+
+const (
+	// HAlignModeDefault matches OpenSCAD: HAlignLeft and HAlignRight use
+	// the pen start and advance, and HAlignCenter the outline bounds.
+	HAlignModeDefault HAlignMode = iota
+	// HAlignModeInk aligns the outline bounds of each line, ignoring side
+	// bearings.
+	HAlignModeInk
+	// HAlignModeAdvance aligns the pen start and advance of each line, so
+	// that lines with the same advance line up regardless of their glyphs.
+	HAlignModeAdvance
 )
 
 type Align struct {
 	HAlign HAlign
 	VAlign VAlign
+
+	// HMode applies to the lines of horizontal text.
+	HMode HAlignMode
 }
 
 type Options struct {
 	Size      float64 // OpenSCAD-like: target ascent (baseline to top) in model units
-	CurveSegs int     // flattening segments per quadratic
+	CurveSegs int     // flattening segments per quadratic or cubic curve
 	Align     Align
 	Kerning   bool
 	Spacing   float64 // OpenSCAD-like spacing multiplier; 0 defaults to 1
+
+	// LetterSpacing adds this many model units between grapheme clusters,
+	// and WordSpacing after each word separator, such as a space. Unlike
+	// Spacing, they do not depend on the size of the glyphs, and may be
+	// negative to tighten the text.
+	LetterSpacing float64
+	WordSpacing   float64
+
+	// LineSpacing scales the distance between the baselines of lines,
+	// which is the font's line height by default; 0 defaults to 1.
+	LineSpacing float64
+
+	// MaxWidth wraps lines at Unicode line break opportunities so that
+	// their advance fits in MaxWidth model units; 0 disables wrapping.
+	// Words that are too long on their own are broken between characters.
+	MaxWidth float64
+
+	// MaxTracking allows HAlignJustify to add up to this much space, in
+	// model units, between the characters of justified lines which have no
+	// gaps between words, such as long single words. Lines with several
+	// words are justified by widening the gaps between them.
+	MaxTracking float64
+
+	// TabStops are the positions that tabs advance to, in increasing order.
+	// After the last one, there is a left-aligned stop every TabWidth model
+	// units, which defaults to eight spaces of the font.
+	TabStops []TabStop
+	TabWidth float64
+
+	// Direction sets the base direction of paragraphs for the bidirectional
+	// algorithm. By default, it is detected from each paragraph's text.
+	Direction Direction
+
+	// Script is the ISO 15924 code of the text's script, such as "Deva".
+	// By default, the text is split into runs of each script it contains.
+	Script string
+
+	// Language is a BCP 47 language tag, such as "sr" or "tr", which
+	// selects language-specific glyph forms. It is unset by default.
+	Language string
+
+	// Vertical lays lines out top to bottom, as columns progressing from
+	// right to left, like vertical CJK text. Glyphs are upright by default,
+	// using the font's vertical metrics and 'vert' substitutions; 'vrt2'
+	// can be enabled with Features.
+	//
+	// Each column is aligned along its advance with VAlign, where
+	// VAlignTop and VAlignBaseline keep the pen start at the anchor, and
+	// VAlignBottom the end of the advance. Metric modes keep the pen start
+	// (VAlignAscender, VAlignCapHeight and VAlignXHeight) or the end
+	// (VAlignDescender) at the anchor, or center the advance
+	// (VAlignEmCenter). HAlign aligns the bounds of the block of columns,
+	// where HAlignStart is the right side.
+	Vertical bool
+
+	// Sideways rotates runs of horizontal scripts, such as Latin, by 90
+	// degrees clockwise in vertical text, following the Unicode
+	// Vertical_Orientation property.
+	Sideways bool
+
+	// Features sets OpenType features, such as "tnum" or "ss01", on all or
+	// part of the text. They override the defaults, including Kerning.
+	Features []Feature
+
+	// Variations selects an instance of a variable font, mapping axis tags
+	// (e.g. "wght", "wdth") to values in design units.
+	// Values outside an axis range are clamped by the font.
+	Variations map[string]float64
+
+	// Palette selects the CPAL palette used by TextColorLayers.
+	Palette int
+
+	// Embolden synthesizes a bold face by widening glyph strokes by this
+	// amount in model units, like FreeType's FT_Outline_Embolden.
+	// Each glyph's advance grows by the same amount.
+	Embolden float64
+
+	// Oblique synthesizes an italic face by shearing glyphs about the
+	// baseline, moving each point right by Oblique times its height.
+	// For example, 0.2 slants glyphs by about 11 degrees.
+	Oblique float64
 }
 
-// ParsedFont stores parsed TrueType data and auxiliary metrics/layout state.
+// ParsedFont stores parsed font data and auxiliary metrics/layout state.
 type ParsedFont struct {
+	// TTFont is the TrueType view of the font.
+	// It is nil for fonts with PostScript (CFF/CFF2) outlines,
+	// whose glyphs are loaded through the go-text backend instead.
 	TTFont *truetype.Font
 
-	ascent float64
-	hbFace *gotextfont.Face
+	ascent      float64
+	hbFace      *gotextfont.Face
+	cffOutlines bool
+	axes        []VariationAxis
+	mvar        *tables.MVAR
+	info        FontInfo
+	metrics     FontMetrics
+	colr        *colrTable
+	palettes    [][]color.NRGBA
+
+	// faceLock guards hbFace, whose caches are not safe for concurrent use.
+	faceLock *sync.Mutex
+
+	cache        *glyphCache
+	variationKey string // identifies the variation instance in cache keys
 }
 
-// ParseTTF parses a TTF/OTF(TrueType outlines) font file.
+// ParseTTF parses a TTF/OTF font file with either TrueType (glyf)
+// or PostScript (CFF/CFF2) outlines. The file may also be wrapped in a
+// WOFF or WOFF2 container.
+//
+// Font collections are loaded as their first face; see ParseTTC.
 func ParseTTF(ttfBytes []byte) (*ParsedFont, error) {
-	ttf, err := truetype.Parse(ttfBytes)
-	if err != nil {
-		return nil, err
+	return ParseTTC(ttfBytes, 0)
+}
+
+// newParsedFont combines the TrueType and go-text views of the face whose
+// table directory starts at dirOffset.
+func newParsedFont(data []byte, dirOffset int, ttf *truetype.Font, ttfErr error,
+	hbFace *gotextfont.Face, hbErr error) (*ParsedFont, error) {
+	if ttfErr != nil {
+		// golang/freetype only understands glyf outlines, so CFF fonts
+		// are handled entirely by the go-text face.
+		if hbErr != nil || !hasCFFOutlines(data, dirOffset) {
+			return nil, ttfErr
+		}
+		ttf = nil
+	}
+	res := &ParsedFont{
+		TTFont:      ttf,
+		cffOutlines: ttf == nil,
+		info:        parseFontInfo(data, dirOffset),
+		metrics:     parseFontMetrics(data, dirOffset),
+		faceLock:    &sync.Mutex{},
 	}
-	res := &ParsedFont{TTFont: ttf}
-	if asc, ok := parseOS2TypoAscender(ttfBytes); ok && asc > 0 {
+	if asc, ok := parseOS2TypoAscender(data, dirOffset); ok && asc > 0 {
 		res.ascent = asc
 	}
-	if hbFace, err := gotextfont.ParseTTF(bytes.NewReader(ttfBytes)); err == nil {
+	if hbErr == nil {
 		res.hbFace = hbFace
+		res.metrics.applyFaceMetrics(hbFace)
+	}
+	if fvar, ok := findSFNTTable(data, dirOffset, "fvar"); ok {
+		var names map[uint16]string
+		if name, ok := findSFNTTable(data, dirOffset, "name"); ok {
+			names = parseNameTable(name)
+		}
+		res.axes = parseFvarAxes(fvar, names)
+	}
+	if table, ok := findSFNTTable(data, dirOffset, "MVAR"); ok {
+		// Metric variations are optional, so malformed tables are ignored.
+		mvar, _, err := tables.ParseMVAR(table)
+		if err == nil && mvar.ItemVariationStore.AxisCount() == len(res.axes) {
+			res.mvar = &mvar
+		}
+	}
+	if table, ok := findSFNTTable(data, dirOffset, "COLR"); ok {
+		// Color data is optional, so malformed tables are ignored and
+		// glyphs are drawn from their plain outlines.
+		if colr, err := parseCOLR(table); err == nil {
+			res.colr = colr
+		}
+	}
+	if table, ok := findSFNTTable(data, dirOffset, "CPAL"); ok {
+		res.palettes = parseCPAL(table)
 	}
 	return res, nil
 }
 
+// UnitsPerEm returns the font's design units per em.
+func (p *ParsedFont) UnitsPerEm() float64 {
+	if p.TTFont != nil {
+		return float64(p.TTFont.FUnitsPerEm())
+	}
+	return float64(p.hbFace.Upem())
+}
+
+// glyphContours loads a glyph and flattens it into polylines offset by penX
+// (in font units) and scaled into model units.
+//
+// The caller may modify the returned contours.
+func (p *ParsedFont) glyphContours(idx truetype.Index, penX, scale float64, segs int) ([]Contour, bool) {
+	if p.cache != nil {
+		return p.cachedGlyphContours(idx, penX, scale, segs)
+	}
+	return p.loadGlyphContours(idx, penX, scale, segs)
+}
+
+func (p *ParsedFont) loadGlyphContours(idx truetype.Index, penX, scale float64, segs int) ([]Contour, bool) {
+	if p.TTFont == nil {
+		// GlyphData prefers color and bitmap data, so the outline is
+		// requested explicitly.
+		outline, ok := p.hbFace.GlyphDataOutline(tables.GlyphID(idx))
+		if !ok {
+			return nil, false
+		}
+		return glyphOutlineToPolylines(outline, penX, scale, segs, p.cffOutlines), true
+	}
+	var gb truetype.GlyphBuf
+	fixedScale := fixed.Int26_6(int32(p.UnitsPerEm() * 64))
+	if err := gb.Load(p.TTFont, fixedScale, idx, xfont.HintingNone); err != nil {
+		return nil, false
+	}
+	return glyphContoursToPolylines(&gb, penX, scale, segs), true
+}
+
 // TextOutlines returns contours for each glyph, already positioned, scaled to Options.Size,
 // and aligned per Options.Align.
-func TextOutlines(parsed *ParsedFont, s string, opt Options) (Outlines, error) {
-	if parsed == nil || parsed.TTFont == nil {
-		return nil, errors.New("nil font")
+//
+// The font is a *ParsedFont, or a *FontSet for glyph fallback.
+func TextOutlines(font FontSource, s string, opt Options) (Outlines, error) {
+	fonts, err := sourceFonts(font)
+	if err != nil {
+		return nil, err
 	}
-	ttFont := parsed.TTFont
-	if opt.Size <= 0 {
-		return nil, errors.New("Size must be > 0")
+	return textOutlines(fonts, s, opt)
+}
+
+// textOutlines lays out s using the first font in fonts that covers each
+// rune. The first font determines the scale of the whole string.
+func textOutlines(fonts []*ParsedFont, s string, opt Options) (Outlines, error) {
+	layout, err := layoutText(fonts, s, opt)
+	if err != nil {
+		return nil, err
+	}
+	return layout.outlines(), nil
+}
+
+// outlines draws the glyphs of the layout and aligns them.
+func (l *textLayout) outlines() Outlines {
+	var items []lineContours
+	for i, line := range l.lines {
+		for _, g := range line.glyphs {
+			contours, ok := g.contours(l.opt.CurveSegs)
+			if !ok {
+				continue
+			}
+			items = append(items, lineContours{line: i, contours: synthesizeStyle(contours, l.opt)})
+		}
 	}
+
+	var outlines Outlines
+	for _, item := range items {
+		outlines = append(outlines, item.contours...)
+	}
+	if len(outlines) == 0 {
+		return nil
+	}
+	l.align(items)
+
+	return outlines
+}
+
+// placedGlyph is a shaped glyph positioned relative to its line.
+type placedGlyph struct {
+	font      *ParsedFont
+	fontIndex int // index into the style's fonts
+	index     truetype.Index
+	penX      float64 // glyph origin in font units
+	penY      float64
+	offsetX   float64 // shaping offset in font units, included in penX and penY
+	offsetY   float64
+	sideways  bool    // rotated 90 degrees clockwise about the origin
+	rtl       bool    // in a right-to-left run
+	scale     float64 // font units -> model units
+	advance   float64 // in model units
+	cluster   int     // rune index within the line
+}
+
+// toModel maps a point of the glyph's outline, in font units relative to
+// the glyph origin, to model units relative to the line.
+func (g *placedGlyph) toModel(p model2d.Coord) model2d.Coord {
+	if g.sideways {
+		p = model2d.XY(p.Y, -p.X)
+	}
+	return model2d.XY((p.X+g.penX)*g.scale, (p.Y+g.penY)*g.scale)
+}
+
+// contours loads the glyph's outline in model units relative to the line.
+func (g *placedGlyph) contours(segs int) ([]Contour, bool) {
+	if !g.sideways && g.penY == 0 {
+		return g.font.glyphContours(g.index, g.penX, g.scale, segs)
+	}
+	contours, ok := g.font.glyphContours(g.index, 0, 1, segs)
+	for _, c := range contours {
+		for i, p := range c {
+			c[i] = g.toModel(p)
+		}
+	}
+	return contours, ok
+}
+
+type layoutLine struct {
+	glyphs   []placedGlyph // in visual order
+	start    int           // offset of the first rune in the text
+	end      int           // offset after the last rune in the text
+	advance  float64       // total advance in model units
+	baseline float64       // offset of the baseline in model units, along x for vertical lines
+	height   float64       // line height in model units
+	metrics  lineMetrics   // in model units
+	rtl      bool          // paragraph direction
+}
+
+type textLayout struct {
+	lines []layoutLine
+	opt   Options // with defaults filled in
+}
+
+// lineContours holds contours drawn for one line of a textLayout.
+type lineContours struct {
+	line     int
+	contours []Contour
+}
+
+// layoutText validates opt, shapes s and positions its glyphs.
+//
+// The text is split into lines at line breaks, and lines are stacked using
+// the primary font's line height times Options.LineSpacing.
+func layoutText(fonts []*ParsedFont, s string, opt Options) (*textLayout, error) {
+	opt, err := checkOptions(opt)
+	if err != nil {
+		return nil, err
+	}
+	text := []rune(s)
+	style, err := newTextStyle(fonts, 0, len(text), opt)
+	if err != nil {
+		return nil, err
+	}
+	return layoutStyled(text, []textStyle{style}, opt), nil
+}
+
+// checkOptions validates the options shared by all styles of a layout, and
+// fills in their defaults.
+func checkOptions(opt Options) (Options, error) {
 	if opt.CurveSegs <= 0 {
 		opt.CurveSegs = 8
 	}
@@ -116,126 +474,526 @@ func TextOutlines(parsed *ParsedFont, s string, opt Options) (Outlines, error) {
 		opt.Spacing = 1
 	}
 	if opt.Spacing < 0 {
-		return nil, errors.New("Spacing must be >= 0")
+		return opt, errors.New("Spacing must be >= 0")
+	}
+	if opt.LineSpacing == 0 {
+		opt.LineSpacing = 1
+	}
+	if opt.LineSpacing < 0 {
+		return opt, errors.New("LineSpacing must be >= 0")
+	}
+	if opt.Embolden < 0 {
+		return opt, errors.New("Embolden must be >= 0")
+	}
+	if opt.MaxWidth < 0 {
+		return opt, errors.New("MaxWidth must be >= 0")
+	}
+	if opt.Align.HAlign == HAlignJustify && opt.MaxWidth == 0 {
+		return opt, errors.New("HAlignJustify requires MaxWidth")
+	}
+	if opt.MaxTracking < 0 {
+		return opt, errors.New("MaxTracking must be >= 0")
+	}
+	if opt.TabWidth < 0 {
+		return opt, errors.New("TabWidth must be >= 0")
+	}
+	if err := checkTabStops(opt.TabStops); err != nil {
+		return opt, err
+	}
+	if _, err := parseScript(opt.Script); err != nil {
+		return opt, err
+	}
+	if _, err := parseLanguage(opt.Language); err != nil {
+		return opt, err
+	}
+	if err := checkFeatures(opt.Features); err != nil {
+		return opt, err
+	}
+	return opt, nil
+}
+
+// textStyle is the font and shaping settings of a range of text.
+type textStyle struct {
+	start, end int               // rune range in the text
+	fonts      []*ParsedFont     // instances, with fallbacks
+	emSize     float64           // in model units
+	lineHeight float64           // in model units, including Options.LineSpacing
+	metrics    lineMetrics       // in model units
+	shift      float64           // baseline shift in model units
+	language   language.Language // parsed Options.Language
+	opt        Options           // Size, Spacing, Kerning and Features of the range
+}
+
+func newTextStyle(fonts []*ParsedFont, start, end int, opt Options) (textStyle, error) {
+	for _, f := range fonts {
+		if f == nil || (f.TTFont == nil && f.hbFace == nil) {
+			return textStyle{}, errors.New("nil font")
+		}
+	}
+	if opt.Size <= 0 {
+		return textStyle{}, errors.New("Size must be > 0")
+	}
+	fonts, err := fontInstances(fonts, opt)
+	if err != nil {
+		return textStyle{}, err
+	}
+	lang, err := parseLanguage(opt.Language)
+	if err != nil {
+		return textStyle{}, err
 	}
 
 	// Scale: map font ascent (baseline->top) -> opt.Size in model units,
 	// to match OpenSCAD's text(size=...).
-	upem := float64(ttFont.FUnitsPerEm())
-	ascent := parsed.ascent
-	if ascent <= 0 {
-		fontBounds := ttFont.Bounds(fixed.Int26_6(ttFont.FUnitsPerEm()))
-		ascent = float64(fontBounds.Max.Y)
+	// Fallback fonts share the primary font's em size.
+	scale := fonts[0].sizeScale(opt.Size)
+	return textStyle{
+		start:      start,
+		end:        end,
+		fonts:      fonts,
+		emSize:     scale * fonts[0].UnitsPerEm(),
+		lineHeight: fonts[0].lineHeight() * scale * opt.LineSpacing,
+		metrics:    fonts[0].lineMetrics().scale(scale),
+		language:   lang,
+		opt:        opt,
+	}, nil
+}
+
+// layoutStyled lays out text whose ranges use the given styles, which must
+// cover the text in order.
+func layoutStyled(text []rune, styles []textStyle, opt Options) *textLayout {
+	// Lines are sub-slices of text, so their offsets follow from their
+	// capacities.
+	offset := func(line []rune) int {
+		return cap(text) - cap(line)
 	}
-	if ascent <= 0 {
-		ascent = upem
+	if opt.TabWidth == 0 && slices.Contains(text, '\t') {
+		opt.TabWidth = defaultTabWidth(styles)
 	}
-	scale := opt.Size / ascent
 
-	// truetype uses 26.6 fixed point "scale" for glyph loading.
-	// We choose a fixed scale proportional to upem so that glyph coords come out in font units,
-	// then apply our own float scale.
-	//
-	// Setting fixedScale = 64*upem makes 1 font unit = 64 in the GlyphBuf.
-	fixedScale := fixed.Int26_6(int32(upem * 64))
+	res := &textLayout{opt: opt}
+	for _, paragraph := range splitLines(text) {
+		rtl := !opt.Vertical && paragraphRTL(paragraph, opt.Direction)
+		lines := [][]rune{paragraph}
+		if opt.MaxWidth > 0 {
+			shaped := shapeLine(styles, paragraph, offset(paragraph), rtl, opt)
+			lines = wrapLine(paragraph, shaped.clusterAdvances(len(paragraph)), opt.MaxWidth)
+		}
+		for i, lineRunes := range lines {
+			line := shapeLine(styles, lineRunes, offset(lineRunes), rtl, opt)
+			if opt.Align.HAlign == HAlignJustify && i+1 < len(lines) {
+				line.justify(lineRunes, opt)
+			}
+			line.height = linesHeight(styles, line.start, line.end)
+			line.metrics = linesMetrics(styles, line.start, line.end)
+			// Lines progress downwards, or columns to the left, leaving
+			// room for the larger of two adjacent lines.
+			if n := len(res.lines); n > 0 {
+				prev := res.lines[n-1]
+				line.baseline = prev.baseline - math.Max(prev.height, line.height)
+			}
+			res.lines = append(res.lines, line)
+		}
+	}
+	return res
+}
 
-	var gb truetype.GlyphBuf
-	var outlines Outlines
+// lineStyles returns the styles used by runes [start, end) of the text, or
+// the style at start for empty ranges, such as blank lines.
+func lineStyles(styles []textStyle, start, end int) []textStyle {
+	if start == end {
+		for i := len(styles) - 1; i >= 0; i-- {
+			if styles[i].start <= start {
+				return styles[i : i+1]
+			}
+		}
+		return nil
+	}
+	// Styles cover the text in order, so the used ones are contiguous.
+	first, last := len(styles), 0
+	for i, style := range styles {
+		if style.start < end && style.end > start {
+			first, last = min(first, i), i+1
+		}
+	}
+	if first >= last {
+		return nil
+	}
+	return styles[first:last]
+}
 
-	// Pen position in font units (float font-units, before applying `scale`).
-	penX := 0.0
-	layoutAdvance := 0.0
+// linesHeight returns the largest line height of the styles used by runes
+// [start, end) of the text, as selected by lineStyles.
+func linesHeight(styles []textStyle, start, end int) float64 {
+	var res float64
+	for _, style := range lineStyles(styles, start, end) {
+		res = math.Max(res, style.lineHeight)
+	}
+	return res
+}
+
+// shapeLine shapes one line of text with font fallback, drawing every font
+// of a style at the same em size in model units.
+//
+// The line is split into directional runs with the bidi algorithm, and
+// glyphs are stored in visual order from left to right.
+func shapeLine(styles []textStyle, runes []rune, start int, rtl bool, opt Options) layoutLine {
+	line := layoutLine{start: start, end: start + len(runes), rtl: rtl}
 
-	// Track overall bounds in model units for alignment.
-	minX, minY := math.Inf(1), math.Inf(1)
-	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	// Options were validated by layoutText.
+	forcedScript, _ := parseScript(opt.Script)
 
-	if hbGlyphs, hbAdvance, ok := shapeGlyphsWithHarfBuzz(parsed, s, opt); ok {
-		layoutAdvance = hbAdvance
-		for _, g := range hbGlyphs {
-			gb = truetype.GlyphBuf{}
-			if err := gb.Load(ttFont, fixedScale, g.index, xfont.HintingNone); err != nil {
+	// Tabs are shaped as spaces, and widened once the line is shaped.
+	shapeRunes := runes
+	hasTabs := false
+	for i, r := range runes {
+		if r == '\t' {
+			if !hasTabs {
+				shapeRunes = append([]rune{}, runes...)
+				hasTabs = true
+			}
+			shapeRunes[i] = ' '
+		}
+	}
+
+	levels := bidiLevels(runes, rtl)
+	if opt.Vertical && !opt.Sideways {
+		// Upright glyphs are always stacked in logical order.
+		levels = make([]uint8, len(runes))
+	}
+
+	var runs []textRun
+	for _, levelRun := range visualRuns(levels) {
+		var levelRuns []textRun
+		for styleIdx, style := range styles {
+			styleStart := max(levelRun.start, style.start-start)
+			styleEnd := min(levelRun.end, style.end-start)
+			if styleStart >= styleEnd {
 				continue
 			}
-			contours := glyphContoursToPolylines(&gb, g.penX, scale, opt.CurveSegs)
-			for _, c := range contours {
-				for _, p := range c {
-					if p.X < minX {
-						minX = p.X
-					}
-					if p.Y < minY {
-						minY = p.Y
-					}
-					if p.X > maxX {
-						maxX = p.X
-					}
-					if p.Y > maxY {
-						maxY = p.Y
+			scriptRuns := []scriptRun{{start: styleStart, end: styleEnd, script: forcedScript}}
+			if forcedScript == 0 {
+				scriptRuns = itemizeScripts(runes[styleStart:styleEnd])
+				for i := range scriptRuns {
+					scriptRuns[i].start += styleStart
+					scriptRuns[i].end += styleStart
+				}
+			}
+			for _, sr := range scriptRuns {
+				for _, vr := range itemizeOrientation(runes, sr, opt) {
+					for _, fr := range itemizeFonts(style.fonts, shapeRunes[vr.start:vr.end]) {
+						run := textRun{
+							style:    styleIdx,
+							font:     fr.font,
+							start:    vr.start + fr.start,
+							end:      vr.start + fr.end,
+							rtl:      levelRun.rtl(),
+							sideways: vr.sideways,
+							script:   sr.script,
+							language: style.language,
+						}
+						levelRuns = append(levelRuns, splitFeatureRuns(run, start, style.opt.Features)...)
 					}
 				}
 			}
-			outlines = append(outlines, contours...)
 		}
-	} else {
-		var prev truetype.Index
-		hasPrev := false
-		for _, r := range s {
-			idx := ttFont.Index(r)
-
-			if opt.Kerning && hasPrev {
-				k := ttFont.Kern(fixedScale, prev, idx) // 26.6
-				penX += (float64(k) / 64.0) * opt.Spacing
+		if levelRun.rtl() {
+			for i, j := 0, len(levelRuns)-1; i < j; i, j = i+1, j-1 {
+				levelRuns[i], levelRuns[j] = levelRuns[j], levelRuns[i]
 			}
+		}
+		runs = append(runs, levelRuns...)
+	}
 
-			gb = truetype.GlyphBuf{}
-			if err := gb.Load(ttFont, fixedScale, idx, xfont.HintingNone); err != nil {
-				adv := ttFont.HMetric(fixedScale, idx).AdvanceWidth
-				penX += (float64(adv) / 64.0) * opt.Spacing
-				prev, hasPrev = idx, true
-				continue
-			}
+	// Pen position along the line in model units.
+	pen := 0.0
 
-			contours := glyphContoursToPolylines(&gb, penX, scale, opt.CurveSegs)
-			for _, c := range contours {
-				for _, p := range c {
-					if p.X < minX {
-						minX = p.X
-					}
-					if p.Y < minY {
-						minY = p.Y
-					}
-					if p.X > maxX {
-						maxX = p.X
-					}
-					if p.Y > maxY {
-						maxY = p.Y
-					}
-				}
+	for _, run := range runs {
+		style := &styles[run.style]
+		font := style.fonts[run.font]
+		runScale := style.emSize / font.UnitsPerEm()
+		glyphs, advance := shapeRun(font, shapeRunes, run, style.opt)
+		shift := style.shift / runScale
+
+		// Synthetic emboldening widens every glyph which advances the pen.
+		extra := 0.0
+		for _, g := range glyphs {
+			placed := placedGlyph{
+				font:      font,
+				fontIndex: run.font,
+				index:     g.index,
+				offsetX:   g.xOffset,
+				offsetY:   g.yOffset,
+				scale:     runScale,
+				advance:   g.advance * runScale,
+				cluster:   g.cluster,
+				rtl:       run.rtl,
 			}
-			outlines = append(outlines, contours...)
-			adv := ttFont.HMetric(fixedScale, idx).AdvanceWidth
-			penX += (float64(adv) / 64.0) * opt.Spacing
-			prev, hasPrev = idx, true
+			runPen := (pen + extra) / runScale
+			switch {
+			case !opt.Vertical:
+				placed.penX, placed.penY = runPen+g.penX, g.penY+shift
+			case run.sideways:
+				// Rotate the horizontal run clockwise, centering its em box
+				// on the column.
+				placed.penX = font.sidewaysBaseline() + g.penY + shift
+				placed.penY = -runPen - g.penX
+				placed.offsetX, placed.offsetY = g.yOffset, -g.xOffset
+				placed.sideways = true
+			default:
+				placed.penX, placed.penY = g.penX+shift, g.penY-runPen
+			}
+			if g.advance != 0 {
+				placed.advance += opt.Embolden
+				extra += opt.Embolden
+			}
+			line.glyphs = append(line.glyphs, placed)
 		}
-		layoutAdvance = penX
+		pen += advance*runScale + extra
 	}
+	line.advance = pen
+	line.applySpacing(runes, opt)
+	if hasTabs {
+		line.applyTabs(runes, opt)
+	}
+	return line
+}
 
-	if len(outlines) == 0 {
-		return nil, nil
+// clusterAdvances returns the advance of each rune of the line in model
+// units, attributing each glyph to the first rune of its cluster.
+func (l *layoutLine) clusterAdvances(numRunes int) []float64 {
+	res := make([]float64, numRunes)
+	for _, g := range l.glyphs {
+		if g.cluster >= 0 && g.cluster < numRunes {
+			res[g.cluster] += g.advance
+		}
+	}
+	return res
+}
+
+// align moves contours onto the baselines of their lines and applies
+// Options.Align, aligning each line horizontally on its own and the whole
+// block vertically. Contours are modified in place.
+//
+// The returned offsets are the translation applied to each line. Lines
+// without contours are only moved onto their baselines.
+func (l *textLayout) align(items []lineContours) []model2d.Coord {
+	byLine := make([][]Contour, len(l.lines))
+	for _, item := range items {
+		byLine[item.line] = append(byLine[item.line], item.contours...)
+	}
+	if l.opt.Vertical {
+		return l.alignVertical(byLine)
+	}
+	offsets := make([]model2d.Coord, len(l.lines))
+	var all []Contour
+	for i, contours := range byLine {
+		offsets[i] = model2d.XY(0, l.lines[i].baseline)
+		if len(contours) == 0 {
+			continue
+		}
+		minX, _, maxX, _ := contourBounds(contours)
+		lineOpt := l.opt
+		lineOpt.Align.HAlign = l.lines[i].hAlign(l.opt.Align.HAlign)
+		dx, _ := computeAlign(lineOpt, minX, 0, maxX, 0, l.lines[i].advance)
+		translateContours(contours, dx, l.lines[i].baseline)
+		offsets[i].X = dx
+		all = append(all, contours...)
+	}
+	if len(all) == 0 {
+		return offsets
 	}
+	_, minY, _, maxY := contourBounds(all)
+	dy := l.blockVAlign(minY, maxY)
+	translateContours(all, 0, dy)
+	for i := range offsets {
+		offsets[i].Y += dy
+	}
+	return offsets
+}
 
-	// Alignment translation
-	dx, dy := computeAlign(opt, minX, minY, maxX, maxY, layoutAdvance*scale)
+// blockVAlign computes the y offset of the block of horizontal lines for
+// VAlign, given the bounds of its outlines.
+func (l *textLayout) blockVAlign(minY, maxY float64) float64 {
+	first, last := l.lines[0], l.lines[len(l.lines)-1]
+	switch l.opt.Align.VAlign {
+	case VAlignAscender:
+		return -(first.baseline + first.metrics.ascender)
+	case VAlignCapHeight:
+		return -(first.baseline + first.metrics.capHeight)
+	case VAlignXHeight:
+		return -(first.baseline + first.metrics.xHeight)
+	case VAlignDescender:
+		return -(last.baseline + last.metrics.descender)
+	case VAlignEmCenter:
+		top := first.baseline + first.metrics.emTop
+		bottom := last.baseline + last.metrics.emBottom
+		return -(top + bottom) / 2
+	}
+	_, dy := computeAlign(l.opt, 0, minY, 0, maxY, 0)
+	return dy
+}
 
-	// Apply translation
-	for i := range outlines {
-		for j := range outlines[i] {
-			outlines[i][j].X += dx
-			outlines[i][j].Y += dy
+// hAlign resolves HAlignStart, HAlignEnd and HAlignJustify for the line's
+// direction. Justified lines already span their width.
+func (l *layoutLine) hAlign(h HAlign) HAlign {
+	switch h {
+	case HAlignStart, HAlignJustify:
+		if l.rtl {
+			return HAlignRight
 		}
+		return HAlignLeft
+	case HAlignEnd:
+		if l.rtl {
+			return HAlignLeft
+		}
+		return HAlignRight
 	}
+	return h
+}
 
-	return outlines, nil
+// lineHeight returns the distance between baselines in font units.
+//
+// This is the OS/2 typographic ascender minus descender plus line gap,
+// falling back to the hhea metrics when the OS/2 values are missing.
+func (p *ParsedFont) lineHeight() float64 {
+	m := p.metrics
+	if h := m.TypoAscender - m.TypoDescender + m.TypoLineGap; m.TypoAscender > 0 && h > 0 {
+		return h
+	}
+	if h := m.HheaAscender - m.HheaDescender + m.HheaLineGap; h > 0 {
+		return h
+	}
+	return p.UnitsPerEm() * 1.2
+}
+
+// lineMetrics holds the vertical metrics of a line relative to its
+// baseline, with y pointing up.
+type lineMetrics struct {
+	ascender, descender float64
+	capHeight, xHeight  float64
+	emTop, emBottom     float64
+}
+
+func (m lineMetrics) scale(s float64) lineMetrics {
+	return lineMetrics{
+		ascender:  m.ascender * s,
+		descender: m.descender * s,
+		capHeight: m.capHeight * s,
+		xHeight:   m.xHeight * s,
+		emTop:     m.emTop * s,
+		emBottom:  m.emBottom * s,
+	}
+}
+
+// lineMetrics returns the metrics of lines set in the font, in font units.
+//
+// The ascender and descender are the ones used by lineHeight, and the em
+// box is split between them in proportion.
+func (p *ParsedFont) lineMetrics() lineMetrics {
+	m := p.metrics
+	upem := p.UnitsPerEm()
+	var res lineMetrics
+	if m.TypoAscender > 0 && m.TypoAscender-m.TypoDescender+m.TypoLineGap > 0 {
+		res.ascender, res.descender = m.TypoAscender, m.TypoDescender
+	} else if m.HheaAscender-m.HheaDescender+m.HheaLineGap > 0 {
+		res.ascender, res.descender = m.HheaAscender, m.HheaDescender
+	} else {
+		res.ascender, res.descender = upem*0.8, -upem*0.2
+	}
+	res.capHeight, res.xHeight = m.CapHeight, m.XHeight
+	if res.capHeight <= 0 {
+		res.capHeight = res.ascender
+	}
+	if res.xHeight <= 0 {
+		res.xHeight = res.capHeight / 2
+	}
+	res.emBottom = -upem * 0.2
+	if extent := res.ascender - res.descender; extent > 0 {
+		res.emBottom = upem * res.descender / extent
+	}
+	res.emTop = res.emBottom + upem
+	return res
+}
+
+// linesMetrics combines the line metrics of the styles used by runes
+// [start, end) of the text, as selected by lineStyles, taking the outermost
+// of each metric.
+func linesMetrics(styles []textStyle, start, end int) lineMetrics {
+	var res lineMetrics
+	for i, style := range lineStyles(styles, start, end) {
+		m := style.metrics
+		if i == 0 {
+			res = m
+			continue
+		}
+		res.ascender = math.Max(res.ascender, m.ascender)
+		res.descender = math.Min(res.descender, m.descender)
+		res.capHeight = math.Max(res.capHeight, m.capHeight)
+		res.xHeight = math.Max(res.xHeight, m.xHeight)
+		res.emTop = math.Max(res.emTop, m.emTop)
+		res.emBottom = math.Min(res.emBottom, m.emBottom)
+	}
+	return res
+}
+
+// splitLines splits text at line breaks (LF, CR, CRLF, NEL, LS and PS).
+func splitLines(runes []rune) [][]rune {
+	var res [][]rune
+	start := 0
+	for i := 0; i < len(runes); i++ {
+		switch runes[i] {
+		case '\n', '\r', '\u0085', '\u2028', '\u2029':
+			res = append(res, runes[start:i])
+			if runes[i] == '\r' && i+1 < len(runes) && runes[i+1] == '\n' {
+				i++
+			}
+			start = i + 1
+		}
+	}
+	return append(res, runes[start:])
+}
+
+// contourBounds returns the bounding box of all points in contours.
+func contourBounds(contours []Contour) (minX, minY, maxX, maxY float64) {
+	minX, minY = math.Inf(1), math.Inf(1)
+	maxX, maxY = math.Inf(-1), math.Inf(-1)
+	for _, c := range contours {
+		for _, p := range c {
+			minX = math.Min(minX, p.X)
+			minY = math.Min(minY, p.Y)
+			maxX = math.Max(maxX, p.X)
+			maxY = math.Max(maxY, p.Y)
+		}
+	}
+	return
+}
+
+func translateContours(contours []Contour, dx, dy float64) {
+	for i := range contours {
+		for j := range contours[i] {
+			contours[i][j].X += dx
+			contours[i][j].Y += dy
+		}
+	}
+}
+
+// sizeScale returns the factor mapping font units to model units such that
+// the font's ascent (baseline to top) spans size model units.
+func (p *ParsedFont) sizeScale(size float64) float64 {
+	ascent := p.ascent
+	if ascent <= 0 && p.TTFont != nil {
+		fontBounds := p.TTFont.Bounds(fixed.Int26_6(p.TTFont.FUnitsPerEm()))
+		ascent = float64(fontBounds.Max.Y)
+	}
+	if ascent <= 0 && p.hbFace != nil {
+		if extents, ok := p.hbFace.FontHExtents(); ok {
+			ascent = float64(extents.Ascender)
+		}
+	}
+	if ascent <= 0 {
+		ascent = p.UnitsPerEm()
+	}
+	return size / ascent
 }
 
 // OutlinesMesh converts text outlines into a single 2D mesh.
@@ -262,16 +1020,25 @@ func computeAlign(opt Options, minX, minY, maxX, maxY, advanceWidth float64) (dx
 	width := maxX - minX
 
 	switch opt.Align.HAlign {
-	case HAlignRight:
+	case HAlignRight, HAlignEnd:
 		// Match OpenSCAD-like behavior: right alignment is relative to the
 		// text origin plus total advance, not the outline's max X.
 		dx = -advanceWidth
+		if opt.Align.HMode == HAlignModeInk {
+			dx = -maxX
+		}
 	case HAlignCenter:
 		dx = -(minX + width/2)
-	case HAlignLeft:
+		if opt.Align.HMode == HAlignModeAdvance {
+			dx = -advanceWidth / 2
+		}
+	case HAlignLeft, HAlignStart, HAlignJustify:
 		// Match OpenSCAD-like behavior: left alignment is relative to the
 		// text origin (pen start), not the outline's leftmost bound.
 		dx = 0
+		if opt.Align.HMode == HAlignModeInk {
+			dx = -minX
+		}
 	default:
 		panic("unknown HAlign")
 	}
@@ -287,6 +1054,10 @@ func computeAlign(opt Options, minX, minY, maxX, maxY, advanceWidth float64) (dx
 		// Keep baseline at y=0. Our outlines are already in a baseline-ish space
 		// because TTF glyph Y is relative to baseline; we invert Y in conversion.
 		dy = 0
+	case VAlignAscender, VAlignCapHeight, VAlignXHeight, VAlignDescender, VAlignEmCenter:
+		// These depend on the font metrics of the lines, which are
+		// applied by textLayout.blockVAlign.
+		dy = 0
 	default:
 		panic("unknown VAlign")
 	}
@@ -428,51 +1199,92 @@ func flattenQuad(p0, p1, p2 model2d.Coord, segs int) []model2d.Coord {
 	return out
 }
 
-func parseOS2TypoAscender(data []byte) (float64, bool) {
-	const (
-		tableDirOffset = 12
-		recordSize     = 16
-		os2Tag         = "OS/2"
-		typoAscOffset  = 68
-	)
-	if len(data) < tableDirOffset {
-		return 0, false
-	}
-	numTables := int(binary.BigEndian.Uint16(data[4:6]))
-	if numTables < 0 || len(data) < tableDirOffset+numTables*recordSize {
-		return 0, false
-	}
-	for i := 0; i < numTables; i++ {
-		recOff := tableDirOffset + i*recordSize
-		tag := string(data[recOff : recOff+4])
-		if tag != os2Tag {
-			continue
-		}
-		tableOffset := int(binary.BigEndian.Uint32(data[recOff+8 : recOff+12]))
-		tableLen := int(binary.BigEndian.Uint32(data[recOff+12 : recOff+16]))
-		if tableOffset < 0 || tableLen < 0 || tableOffset+tableLen > len(data) || tableLen < typoAscOffset+2 {
-			return 0, false
-		}
-		raw := int16(binary.BigEndian.Uint16(data[tableOffset+typoAscOffset : tableOffset+typoAscOffset+2]))
-		return float64(raw), raw > 0
+type positionedGlyph struct {
+	index   truetype.Index
+	penX    float64 // glyph origin in font units, relative to the run start
+	penY    float64 // negative along vertical runs
+	xOffset float64 // offset of the origin from the pen, included in penX and penY
+	yOffset float64
+	advance float64 // in font units along the run, including spacing
+	cluster int     // index of the first rune of the glyph's cluster
+}
+
+// textRun is a range of runes shaped in one go, with a single font,
+// direction and script.
+type textRun struct {
+	style      int // index into the styles of the layout
+	font       int // index into the style's fonts
+	start, end int // rune range
+	rtl        bool
+	sideways   bool // rotated in vertical text
+	script     language.Script
+	language   language.Language
+	features   []shaping.FontFeature
+}
+
+// shapeRun shapes runes[run.start:run.end], preferring HarfBuzz and falling
+// back to the TrueType cmap and kern tables.
+// The returned positions and advance are in font units.
+func shapeRun(parsed *ParsedFont, runes []rune, run textRun, opt Options) ([]positionedGlyph, float64) {
+	if glyphs, advance, ok := shapeGlyphsWithHarfBuzz(parsed, runes, run, opt); ok {
+		return glyphs, advance
 	}
-	return 0, false
+	return shapeGlyphsWithTrueType(parsed, runes, run, opt)
 }
 
-type positionedGlyph struct {
-	index truetype.Index
-	penX  float64 // in font units
+func shapeGlyphsWithTrueType(parsed *ParsedFont, runes []rune, run textRun, opt Options) ([]positionedGlyph, float64) {
+	start, end := run.start, run.end
+	ttFont := parsed.TTFont
+	if ttFont == nil {
+		return nil, 0
+	}
+
+	// Setting fixedScale = 64*upem makes 1 font unit = 64 in 26.6 metrics.
+	fixedScale := fixed.Int26_6(int32(parsed.UnitsPerEm() * 64))
+
+	res := make([]positionedGlyph, 0, end-start)
+	penX := 0.0
+	var prev truetype.Index
+	hasPrev := false
+	for i := range runes[start:end] {
+		// Glyphs are produced in visual order, so RTL runs are reversed.
+		cluster := start + i
+		if run.rtl {
+			cluster = end - 1 - i
+		}
+		idx := ttFont.Index(runes[cluster])
+
+		if opt.Kerning && hasPrev {
+			k := ttFont.Kern(fixedScale, prev, idx) // 26.6
+			penX += (float64(k) / 64.0) * opt.Spacing
+		}
+
+		hAdv := float64(ttFont.HMetric(fixedScale, idx).AdvanceWidth) / 64.0
+		if opt.Vertical && !run.sideways {
+			// Without vertical metrics, center glyphs horizontally and
+			// advance by one em, as HarfBuzz does for fonts without vmtx.
+			adv := parsed.UnitsPerEm() * opt.Spacing
+			res = append(res, positionedGlyph{index: idx, penX: -hAdv / 2, penY: -penX - parsed.ascent,
+				xOffset: -hAdv / 2, yOffset: -parsed.ascent, advance: adv, cluster: cluster})
+			penX += adv
+			continue
+		}
+		adv := hAdv * opt.Spacing
+		res = append(res, positionedGlyph{index: idx, penX: penX, advance: adv, cluster: cluster})
+		penX += adv
+		prev, hasPrev = idx, true
+	}
+	return res, penX
 }
 
-func shapeGlyphsWithHarfBuzz(parsed *ParsedFont, s string, opt Options) ([]positionedGlyph, float64, bool) {
-	if parsed == nil || parsed.hbFace == nil || parsed.TTFont == nil {
+func shapeGlyphsWithHarfBuzz(parsed *ParsedFont, runes []rune, run textRun,
+	opt Options) ([]positionedGlyph, float64, bool) {
+	if parsed == nil || parsed.hbFace == nil {
 		return nil, 0, false
 	}
-	ttFont := parsed.TTFont
 	hbFace := parsed.hbFace
 
-	runes := []rune(s)
-	if len(runes) == 0 {
+	if run.start == run.end {
 		return nil, 0, true
 	}
 
@@ -482,26 +1294,50 @@ func shapeGlyphsWithHarfBuzz(parsed *ParsedFont, s string, opt Options) ([]posit
 		features = append(features, shaping.FontFeature{Tag: hbFeatureTags.kern, Value: 0})
 	}
 
+	direction := di.DirectionLTR
+	if opt.Vertical && !run.sideways {
+		direction = di.DirectionTTB
+	} else if run.rtl {
+		direction = di.DirectionRTL
+	}
+	features = append(features, run.features...)
+
+	parsed.faceLock.Lock()
+	defer parsed.faceLock.Unlock()
+
 	shaper := shaping.HarfbuzzShaper{}
 	out := shaper.Shape(shaping.Input{
 		Text:         runes,
-		RunStart:     0,
-		RunEnd:       len(runes),
-		Direction:    di.DirectionLTR,
+		RunStart:     run.start,
+		RunEnd:       run.end,
+		Direction:    direction,
+		Script:       run.script,
+		Language:     run.language,
 		Face:         hbFace,
 		FontFeatures: features,
-		Size:         fixed.I(int(ttFont.FUnitsPerEm())),
+		Size:         fixed.I(int(parsed.UnitsPerEm())),
 	})
 
 	res := make([]positionedGlyph, 0, len(out.Glyphs))
-	penX := 0.0
+	pen := 0.0
 	for _, g := range out.Glyphs {
 		xOffset := float64(out.ToFontUnit(g.XOffset))
-		res = append(res, positionedGlyph{
-			index: truetype.Index(g.GlyphID),
-			penX:  penX + xOffset,
-		})
-		penX += float64(out.ToFontUnit(g.XAdvance)) * opt.Spacing
+		yOffset := float64(out.ToFontUnit(g.YOffset))
+		glyph := positionedGlyph{index: truetype.Index(g.GlyphID), xOffset: xOffset, yOffset: yOffset,
+			cluster: g.ClusterIndex}
+		if direction == di.DirectionTTB {
+			// Offsets are relative to the vertical origin, and the advance
+			// is negative since the pen moves down.
+			glyph.advance = -float64(out.ToFontUnit(g.YAdvance)) * opt.Spacing
+			glyph.penX = xOffset
+			glyph.penY = -pen + yOffset
+		} else {
+			glyph.advance = float64(out.ToFontUnit(g.XAdvance)) * opt.Spacing
+			glyph.penX = pen + xOffset
+			glyph.penY = yOffset
+		}
+		res = append(res, glyph)
+		pen += glyph.advance
 	}
-	return res, penX, true
+	return res, pen, true
 }
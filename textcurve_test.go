@@ -168,6 +168,207 @@ func TestOpenSCADSpacingParity(t *testing.T) {
 	}
 }
 
+func TestMultilineLayout(t *testing.T) {
+	font := parseTestFont(t)
+	metrics := font.Metrics()
+	scale := textSize / metrics.TypoAscender
+	lineHeight := (metrics.TypoAscender - metrics.TypoDescender + metrics.TypoLineGap) * scale
+
+	for _, hAlign := range []HAlign{HAlignLeft, HAlignCenter, HAlignRight} {
+		opt := Options{
+			Size:        textSize,
+			Align:       Align{HAlign: hAlign},
+			LineSpacing: 1.5,
+		}
+		line1, err := TextOutlines(font, "Hi", opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		line2, err := TextOutlines(font, "Wide", opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, text := range []string{"Hi\nWide", "Hi\r\nWide", "Hi\u2028Wide"} {
+			block, err := TextOutlines(font, text, opt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(block) != len(line1)+len(line2) {
+				t.Fatalf("%q: expected %d contours but got %d", text, len(line1)+len(line2), len(block))
+			}
+			// Each line is aligned on its own.
+			assertSameBounds(t, "line 1", block[:len(line1)], line1, 0)
+			shifted := append(Outlines{}, block[len(line1):]...)
+			translateContours(shifted, 0, 1.5*lineHeight)
+			assertSameBounds(t, "line 2", shifted, line2, 0)
+		}
+	}
+
+	// Vertical alignment applies to the whole block.
+	for _, vAlign := range []VAlign{VAlignTop, VAlignCenter, VAlignBottom} {
+		opt := Options{Size: textSize, Align: Align{VAlign: vAlign}}
+		block, err := TextOutlines(font, "Hi\n\ngj", opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		min, max := outlinesBounds(block)
+		var expected float64
+		switch vAlign {
+		case VAlignTop:
+			expected = max.Y
+		case VAlignCenter:
+			expected = (min.Y + max.Y) / 2
+		case VAlignBottom:
+			expected = min.Y
+		}
+		if math.Abs(expected) > 1e-8 {
+			t.Errorf("VAlign %d: block not aligned (bounds %v-%v)", vAlign, min, max)
+		}
+		if height := max.Y - min.Y; height < 2*lineHeight {
+			t.Errorf("VAlign %d: expected three lines but got height %f", vAlign, height)
+		}
+	}
+}
+
+func TestMetricAlign(t *testing.T) {
+	font := parseTestFont(t)
+	metrics, err := font.ScaledMetrics(Options{Size: textSize})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lineHeight := metrics.TypoAscender - metrics.TypoDescender + metrics.TypoLineGap
+	emBottom := metrics.UnitsPerEm * metrics.TypoDescender / (metrics.TypoAscender - metrics.TypoDescender)
+
+	baselines := func(text string, align Align) []float64 {
+		glyphs, err := TextGlyphs(font, text, Options{Size: textSize, Align: align})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var res []float64
+		for _, g := range glyphs {
+			if len(res) <= g.Line {
+				res = append(res, g.Pen.Y)
+			}
+		}
+		return res
+	}
+
+	for _, c := range []struct {
+		vAlign   VAlign
+		baseline float64
+	}{
+		{VAlignAscender, -metrics.TypoAscender},
+		{VAlignCapHeight, -metrics.CapHeight},
+		{VAlignXHeight, -metrics.XHeight},
+		{VAlignDescender, -metrics.TypoDescender},
+		{VAlignEmCenter, -(emBottom + metrics.UnitsPerEm/2)},
+	} {
+		// Baselines do not depend on the glyphs.
+		for _, text := range []string{"Aa", "ag", "x"} {
+			actual := baselines(text, Align{VAlign: c.vAlign})
+			if math.Abs(actual[0]-c.baseline) > 1e-8 {
+				t.Errorf("VAlign %d %q: expected baseline %f but got %f", c.vAlign, text, c.baseline, actual[0])
+			}
+		}
+		// The first line's top and last line's bottom are aligned.
+		actual := baselines("A\nb\nc", Align{VAlign: c.vAlign})
+		expected := c.baseline
+		switch c.vAlign {
+		case VAlignDescender:
+			expected += 2 * lineHeight
+		case VAlignEmCenter:
+			expected += lineHeight
+		}
+		if math.Abs(actual[0]-expected) > 1e-8 {
+			t.Errorf("VAlign %d: expected first baseline %f but got %f", c.vAlign, expected, actual[0])
+		}
+	}
+
+	// Metric modes are supported along columns.
+	for _, vAlign := range []VAlign{VAlignAscender, VAlignDescender, VAlignEmCenter} {
+		opt := Options{Size: textSize, Vertical: true, Align: Align{VAlign: vAlign}}
+		if _, err := TextOutlines(font, "ab", opt); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestHAlignMode(t *testing.T) {
+	font := parseTestFont(t)
+	for _, hAlign := range []HAlign{HAlignLeft, HAlignCenter, HAlignRight} {
+		for _, mode := range []HAlignMode{HAlignModeDefault, HAlignModeInk, HAlignModeAdvance} {
+			opt := Options{Size: textSize, Align: Align{HAlign: hAlign, HMode: mode}}
+			glyphs, err := TextGlyphs(font, "Wall", opt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var outlines Outlines
+			for _, g := range glyphs {
+				outlines = append(outlines, g.Outlines...)
+			}
+			min, max := outlinesBounds(outlines)
+			last := glyphs[len(glyphs)-1]
+			start, end := glyphs[0].Pen.X, last.Pen.X+last.Advance
+
+			ink := hAlign != HAlignCenter && mode == HAlignModeInk ||
+				hAlign == HAlignCenter && mode != HAlignModeAdvance
+			var actual float64
+			switch {
+			case hAlign == HAlignLeft && ink:
+				actual = min.X
+			case hAlign == HAlignLeft:
+				actual = start
+			case hAlign == HAlignCenter && ink:
+				actual = (min.X + max.X) / 2
+			case hAlign == HAlignCenter:
+				actual = (start + end) / 2
+			case hAlign == HAlignRight && ink:
+				actual = max.X
+			default:
+				actual = end
+			}
+			if math.Abs(actual) > 1e-8 {
+				t.Errorf("HAlign %d, mode %d: aligned edge at %f", hAlign, mode, actual)
+			}
+		}
+	}
+}
+
+func parseTestFont(t *testing.T) *ParsedFont {
+	fontBytes, err := os.ReadFile(filepath.Join("test_data", "LiberationSans-Regular.ttf"))
+	if err != nil {
+		t.Fatalf("read font: %v", err)
+	}
+	font, err := ParseTTF(fontBytes)
+	if err != nil {
+		t.Fatalf("parse font: %v", err)
+	}
+	return font
+}
+
+func outlinesBounds(outlines Outlines) (min, max model2d.Coord) {
+	min = model2d.XY(math.Inf(1), math.Inf(1))
+	max = model2d.XY(math.Inf(-1), math.Inf(-1))
+	for _, c := range outlines {
+		for _, p := range c {
+			min = min.Min(p)
+			max = max.Max(p)
+		}
+	}
+	return min, max
+}
+
+func assertSameBounds(t *testing.T, name string, actual, expected Outlines, dx float64) {
+	actualMin, actualMax := outlinesBounds(actual)
+	expectedMin, expectedMax := outlinesBounds(expected)
+	expectedMin.X += dx
+	expectedMax.X += dx
+	if actualMin.Dist(expectedMin) > 1e-6 || actualMax.Dist(expectedMax) > 1e-6 {
+		t.Errorf("%s: expected bounds %v-%v but got %v-%v", name, expectedMin, expectedMax,
+			actualMin, actualMax)
+	}
+}
+
 func renderOpenSCAD(openscadPath, tempDir, fontPath, text string, opt Options) (string, error) {
 	scadPath := filepath.Join(tempDir, fmt.Sprintf("text_%s_%s_%s.scad", sanitizeName(text), scadHAlign(opt.Align.HAlign), scadVAlign(opt.Align.VAlign)))
 	stlPath := strings.TrimSuffix(scadPath, ".scad") + ".stl"
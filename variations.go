@@ -0,0 +1,145 @@
+package textcurve
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	gotextfont "github.com/go-text/typesetting/font"
+	ot "github.com/go-text/typesetting/font/opentype"
+	"github.com/go-text/typesetting/font/opentype/tables"
+)
+
+// VariationAxis describes one design axis of a variable font.
+type VariationAxis struct {
+	Tag  string // e.g. "wght", "wdth", "opsz", "slnt"
+	Name string
+
+	Min     float64
+	Default float64
+	Max     float64
+}
+
+// VariationAxes returns the design axes of a variable font,
+// or nil for static fonts.
+func (p *ParsedFont) VariationAxes() []VariationAxis {
+	return append([]VariationAxis{}, p.axes...)
+}
+
+// instance returns a copy of the font with the variation coordinates from
+// opt applied, or the font itself when no variations are requested.
+//
+// golang/freetype ignores gvar, so the copy always loads glyphs through the
+// go-text face, which applies gvar (or CFF2 blend) deltas to outlines and
+// HVAR to advances, and is also what the shaper sees.
+func (p *ParsedFont) instance(opt Options) (*ParsedFont, error) {
+	if len(opt.Variations) == 0 {
+		return p, nil
+	}
+	if p.hbFace == nil || len(p.axes) == 0 {
+		return nil, errors.New("font has no variation axes")
+	}
+	tags := make([]string, 0, len(opt.Variations))
+	for tag := range opt.Variations {
+		if !p.hasAxis(tag) {
+			return nil, fmt.Errorf("unknown variation axis %q", tag)
+		}
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	variations := make([]gotextfont.Variation, len(tags))
+	var key strings.Builder
+	for i, tag := range tags {
+		variations[i] = gotextfont.Variation{
+			Tag:   ot.MustNewTag(tag),
+			Value: float32(opt.Variations[tag]),
+		}
+		fmt.Fprintf(&key, "%s=%v;", tag, variations[i].Value)
+	}
+
+	// Faces are not safe for concurrent use, so each instance gets its own.
+	face := gotextfont.NewFace(p.hbFace.Font)
+	face.SetVariations(variations)
+
+	res := *p
+	res.TTFont = nil
+	res.hbFace = face
+	res.faceLock = &sync.Mutex{}
+	res.variationKey = key.String()
+	res.metrics.applyFaceMetrics(face)
+
+	// Apply the MVAR line metric deltas. MVAR has no tags for the hhea
+	// values, which follow the typo metrics as in HarfBuzz.
+	coords := face.Coords()
+	ascDelta := p.mvarDelta("hasc", coords)
+	descDelta := p.mvarDelta("hdsc", coords)
+	gapDelta := p.mvarDelta("hlgp", coords)
+	if res.ascent > 0 {
+		res.ascent += ascDelta
+	}
+	res.metrics.TypoAscender += ascDelta
+	res.metrics.TypoDescender += descDelta
+	res.metrics.TypoLineGap += gapDelta
+	res.metrics.HheaAscender += ascDelta
+	res.metrics.HheaDescender += descDelta
+	res.metrics.HheaLineGap += gapDelta
+	res.metrics.WinAscent += p.mvarDelta("hcla", coords)
+	res.metrics.WinDescent -= p.mvarDelta("hcld", coords) // usWinDescent is positive
+	return &res, nil
+}
+
+// mvarDelta returns the MVAR delta of a font-wide metric at normalized
+// variation coordinates, or 0 if the metric does not vary.
+func (p *ParsedFont) mvarDelta(tag string, coords []tables.Coord) float64 {
+	if p.mvar == nil {
+		return 0
+	}
+	valueTag := ot.MustNewTag(tag)
+	for _, rec := range p.mvar.ValueRecords {
+		if rec.ValueTag == valueTag {
+			return float64(p.mvar.ItemVariationStore.GetDelta(rec.Index, coords))
+		}
+	}
+	return 0
+}
+
+func (p *ParsedFont) hasAxis(tag string) bool {
+	for _, axis := range p.axes {
+		if axis.Tag == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// parseFvarAxes reads the axis records of an 'fvar' table.
+func parseFvarAxes(table []byte, names map[uint16]string) []VariationAxis {
+	const headerSize = 16
+	if len(table) < headerSize {
+		return nil
+	}
+	axesOffset := int(binary.BigEndian.Uint16(table[4:6]))
+	axisCount := int(binary.BigEndian.Uint16(table[8:10]))
+	axisSize := int(binary.BigEndian.Uint16(table[10:12]))
+	if axisSize < 20 || axesOffset+axisCount*axisSize > len(table) {
+		return nil
+	}
+	fixed := func(b []byte) float64 {
+		return float64(int32(binary.BigEndian.Uint32(b))) / 65536
+	}
+	res := make([]VariationAxis, axisCount)
+	for i := range res {
+		rec := table[axesOffset+i*axisSize:]
+		res[i] = VariationAxis{
+			Tag:     string(rec[0:4]),
+			Name:    names[binary.BigEndian.Uint16(rec[18:20])],
+			Min:     fixed(rec[4:8]),
+			Default: fixed(rec[8:12]),
+			Max:     fixed(rec[12:16]),
+		}
+	}
+	return res
+}
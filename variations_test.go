@@ -0,0 +1,294 @@
+package textcurve
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func TestParseFvarAxes(t *testing.T) {
+	table := make([]byte, 16+20*2)
+	binary.BigEndian.PutUint16(table[0:], 1)
+	binary.BigEndian.PutUint16(table[4:], 16)
+	binary.BigEndian.PutUint16(table[8:], 2)
+	binary.BigEndian.PutUint16(table[10:], 20)
+	for i, axis := range []struct {
+		tag           string
+		min, def, max int32
+		nameID        uint16
+	}{
+		{"wght", 100, 400, 900, 256},
+		{"slnt", -12, 0, 0, 257},
+	} {
+		rec := table[16+20*i:]
+		copy(rec, axis.tag)
+		binary.BigEndian.PutUint32(rec[4:], uint32(axis.min<<16))
+		binary.BigEndian.PutUint32(rec[8:], uint32(axis.def<<16))
+		binary.BigEndian.PutUint32(rec[12:], uint32(axis.max<<16))
+		binary.BigEndian.PutUint16(rec[18:], axis.nameID)
+	}
+
+	axes := parseFvarAxes(table, map[uint16]string{256: "Weight", 257: "Slant"})
+	expected := []VariationAxis{
+		{Tag: "wght", Name: "Weight", Min: 100, Default: 400, Max: 900},
+		{Tag: "slnt", Name: "Slant", Min: -12, Default: 0, Max: 0},
+	}
+	if len(axes) != len(expected) {
+		t.Fatalf("expected %d axes, got %d", len(expected), len(axes))
+	}
+	for i, axis := range axes {
+		if axis != expected[i] {
+			t.Errorf("axis %d: expected %+v, got %+v", i, expected[i], axis)
+		}
+	}
+}
+
+func TestVariationsStaticFont(t *testing.T) {
+	font := parseTestFont(t)
+	if axes := font.VariationAxes(); len(axes) != 0 {
+		t.Fatalf("unexpected axes: %v", axes)
+	}
+	_, err := TextOutlines(font, "Hi", Options{Size: 10, Variations: map[string]float64{"wght": 700}})
+	if err == nil {
+		t.Fatal("expected error for static font")
+	}
+}
+
+func TestVariationsGvar(t *testing.T) {
+	fontBytes, err := os.ReadFile(filepath.Join("test_data", "LiberationSans-Regular.ttf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	const gidH = 43
+	font, err := ParseTTF(addTestTables(
+		fontBytes,
+		sfntTable{"fvar", buildTestFvar("wght", 100, 400, 900)},
+		sfntTable{"gvar", buildTestGvar(fontBytes, gidH)},
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	axes := font.VariationAxes()
+	if len(axes) != 1 || axes[0].Tag != "wght" || axes[0].Min != 100 || axes[0].Max != 900 {
+		t.Fatalf("unexpected axes: %+v", axes)
+	}
+
+	measure := func(weight float64) (width, advance float64) {
+		opt := Options{Size: 10, Variations: map[string]float64{"wght": weight}}
+		glyphs, err := TextGlyphs(font, "HH", opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(glyphs) != 2 || glyphs[0].Index != gidH {
+			t.Fatalf("unexpected glyphs: %+v", glyphs)
+		}
+		if glyphs[1].Pen.X != glyphs[0].Advance {
+			t.Fatalf("weight %v: second glyph at %f for advance %f", weight, glyphs[1].Pen.X,
+				glyphs[0].Advance)
+		}
+		return glyphs[0].Max.X - glyphs[0].Min.X, glyphs[0].Advance
+	}
+
+	// The variation widens the glyph and its advance by 10% at the
+	// maximum weight.
+	baseWidth, baseAdvance := measure(400)
+	for _, c := range []struct {
+		weight float64
+		scale  float64
+	}{
+		{100, 1},
+		{650, 1.05},
+		{900, 1.1},
+		{0, 1},
+		{2000, 1.1},
+	} {
+		width, advance := measure(c.weight)
+		if math.Abs(width/baseWidth-c.scale) > 1e-3 {
+			t.Errorf("weight %v: expected width scale %f but got %f", c.weight, c.scale, width/baseWidth)
+		}
+		if math.Abs(advance/baseAdvance-c.scale) > 1e-3 {
+			t.Errorf("weight %v: expected advance scale %f but got %f", c.weight, c.scale,
+				advance/baseAdvance)
+		}
+	}
+}
+
+func TestVariationsMVAR(t *testing.T) {
+	fontBytes, err := os.ReadFile(filepath.Join("test_data", "LiberationSans-Regular.ttf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	deltas := map[string]int16{
+		"hasc": 100,
+		"hcla": 200,
+		"hcld": 50,
+		"hdsc": -30,
+		"hlgp": 10,
+	}
+	font, err := ParseTTF(addTestTables(
+		fontBytes,
+		sfntTable{"fvar", buildTestFvar("wght", 100, 400, 900)},
+		sfntTable{"MVAR", buildTestMVAR(deltas)},
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := font.Metrics()
+
+	for _, weight := range []float64{400, 650, 900} {
+		opt := Options{Size: 10, Variations: map[string]float64{"wght": weight}}
+		metrics, err := font.ScaledMetrics(opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		scale := (weight - 400) / 500
+		expected := base
+		expected.TypoAscender += 100 * scale
+		expected.TypoDescender -= 30 * scale
+		expected.TypoLineGap += 10 * scale
+		expected.HheaAscender += 100 * scale
+		expected.HheaDescender -= 30 * scale
+		expected.HheaLineGap += 10 * scale
+		expected.WinAscent += 200 * scale
+		expected.WinDescent -= 50 * scale
+		expected = expected.Scale(opt.Size / expected.TypoAscender)
+		for _, c := range []struct {
+			name             string
+			actual, expected float64
+		}{
+			{"TypoAscender", metrics.TypoAscender, expected.TypoAscender},
+			{"TypoDescender", metrics.TypoDescender, expected.TypoDescender},
+			{"TypoLineGap", metrics.TypoLineGap, expected.TypoLineGap},
+			{"HheaAscender", metrics.HheaAscender, expected.HheaAscender},
+			{"HheaDescender", metrics.HheaDescender, expected.HheaDescender},
+			{"HheaLineGap", metrics.HheaLineGap, expected.HheaLineGap},
+			{"WinAscent", metrics.WinAscent, expected.WinAscent},
+			{"WinDescent", metrics.WinDescent, expected.WinDescent},
+		} {
+			if math.Abs(c.actual-c.expected) > 1e-3 {
+				t.Errorf("weight %v: expected %s %f but got %f", weight, c.name, c.expected, c.actual)
+			}
+		}
+	}
+}
+
+// buildTestMVAR creates an 'MVAR' table for a single axis, whose deltas
+// apply at the axis maximum.
+func buildTestMVAR(deltas map[string]int16) []byte {
+	var tags []string
+	for tag := range deltas {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	// The item variation store has one region and one delta set per tag.
+	var store bytes.Buffer
+	for _, v := range []any{
+		uint16(1),  // format
+		uint32(12), // variationRegionListOffset
+		uint16(1),  // itemVariationDataCount
+		uint32(22), // itemVariationDataOffsets[0]
+		uint16(1),  // axisCount
+		uint16(1),  // regionCount
+		[3]int16{0, 0x4000, 0x4000},
+		uint16(len(tags)), // itemCount
+		uint16(1),         // wordDeltaCount
+		uint16(1),         // regionIndexCount
+		uint16(0),         // regionIndexes[0]
+	} {
+		binary.Write(&store, binary.BigEndian, v)
+	}
+	for _, tag := range tags {
+		binary.Write(&store, binary.BigEndian, deltas[tag])
+	}
+
+	var table bytes.Buffer
+	for _, v := range []uint16{1, 0, 0, 8, uint16(len(tags)), uint16(12 + 8*len(tags))} {
+		binary.Write(&table, binary.BigEndian, v)
+	}
+	for i, tag := range tags {
+		table.WriteString(tag)
+		binary.Write(&table, binary.BigEndian, [2]uint16{0, uint16(i)})
+	}
+	table.Write(store.Bytes())
+	return table.Bytes()
+}
+
+// buildTestFvar creates an 'fvar' table with a single axis.
+func buildTestFvar(tag string, min, def, max int32) []byte {
+	table := make([]byte, 16+20)
+	binary.BigEndian.PutUint16(table[0:], 1)
+	binary.BigEndian.PutUint16(table[4:], 16)
+	binary.BigEndian.PutUint16(table[6:], 2)
+	binary.BigEndian.PutUint16(table[8:], 1)
+	binary.BigEndian.PutUint16(table[10:], 20)
+	binary.BigEndian.PutUint16(table[14:], 8)
+	rec := table[16:]
+	copy(rec, tag)
+	binary.BigEndian.PutUint32(rec[4:], uint32(min<<16))
+	binary.BigEndian.PutUint32(rec[8:], uint32(def<<16))
+	binary.BigEndian.PutUint32(rec[12:], uint32(max<<16))
+	binary.BigEndian.PutUint16(rec[18:], 256)
+	return table
+}
+
+// buildTestGvar creates a 'gvar' table for a single axis, which scales the
+// x coordinates and advance of one simple glyph by 10% at the axis maximum.
+func buildTestGvar(font []byte, gid int) []byte {
+	glyphs := testGlyphs(font)
+	glyph := decodeTestSimpleGlyph(glyphs[gid])
+	hmtx, _ := findSFNTTable(font, 0, "hmtx")
+	advance := int(binary.BigEndian.Uint16(hmtx[4*gid:]))
+
+	// Deltas apply to the points followed by four phantom points, the
+	// second of which is the advance.
+	dx := make([]int, 0, len(glyph.x)+4)
+	for _, x := range glyph.x {
+		dx = append(dx, int(math.Round(float64(x)/10)))
+	}
+	dx = append(dx, 0, int(math.Round(float64(advance)/10)), 0, 0)
+
+	var deltas bytes.Buffer
+	deltas.WriteByte(0) // all points
+	for i := 0; i < len(dx); i += 64 {
+		run := dx[i:min(i+64, len(dx))]
+		deltas.WriteByte(0x40 | byte(len(run)-1))
+		for _, d := range run {
+			binary.Write(&deltas, binary.BigEndian, int16(d))
+		}
+	}
+	for i := 0; i < len(dx); i += 64 {
+		deltas.WriteByte(0x80 | byte(min(64, len(dx)-i)-1))
+	}
+
+	var variation bytes.Buffer
+	for _, v := range []uint16{
+		1,                    // tupleVariationCount
+		10,                   // dataOffset
+		uint16(deltas.Len()), // variationDataSize
+		0x8000 | 0x2000,      // embedded peak, private points
+		0x4000,               // peak at the axis maximum
+	} {
+		binary.Write(&variation, binary.BigEndian, v)
+	}
+	variation.Write(deltas.Bytes())
+
+	headerSize := 20 + 4*(len(glyphs)+1)
+	table := make([]byte, headerSize)
+	binary.BigEndian.PutUint16(table[0:], 1)
+	binary.BigEndian.PutUint16(table[4:], 1)
+	binary.BigEndian.PutUint32(table[8:], uint32(headerSize))
+	binary.BigEndian.PutUint16(table[12:], uint16(len(glyphs)))
+	binary.BigEndian.PutUint16(table[14:], 1) // long offsets
+	binary.BigEndian.PutUint32(table[16:], uint32(headerSize))
+	for i := range glyphs {
+		if i >= gid {
+			binary.BigEndian.PutUint32(table[20+4*(i+1):], uint32(variation.Len()))
+		}
+	}
+	return append(table, variation.Bytes()...)
+}
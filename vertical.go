@@ -0,0 +1,104 @@
+package textcurve
+
+import (
+	"github.com/go-text/typesetting/unicodedata"
+	"github.com/unixpickle/model3d/model2d"
+)
+
+// orientationRun is a range of runes which are all upright or all sideways
+// in vertical text.
+type orientationRun struct {
+	start, end int
+	sideways   bool
+}
+
+// itemizeOrientation splits a script run of vertical text into upright and
+// sideways runs, following the Unicode Vertical_Orientation property of
+// its script when Options.Sideways is set.
+func itemizeOrientation(runes []rune, run scriptRun, opt Options) []orientationRun {
+	if !opt.Vertical || !opt.Sideways {
+		return []orientationRun{{start: run.start, end: run.end}}
+	}
+	vo := unicodedata.LookupVerticalOrientation(run.script)
+	var res []orientationRun
+	for i := run.start; i < run.end; i++ {
+		sideways := vo.Orientation(runes[i])
+		if n := len(res); n > 0 && res[n-1].sideways == sideways {
+			res[n-1].end = i + 1
+		} else {
+			res = append(res, orientationRun{start: i, end: i + 1, sideways: sideways})
+		}
+	}
+	return res
+}
+
+// sidewaysBaseline returns the x offset, in font units, of the baseline of
+// sideways text which centers its em box on a column.
+func (p *ParsedFont) sidewaysBaseline() float64 {
+	ascent, descent := p.metrics.HheaAscender, p.metrics.HheaDescender
+	if ascent <= 0 {
+		ascent, descent = p.ascent, 0
+	}
+	return -(ascent + descent) / 2
+}
+
+// alignVertical is like align for vertical text: each column is aligned
+// along its advance with VAlign, and the block of columns horizontally with
+// HAlign.
+func (l *textLayout) alignVertical(byLine [][]Contour) []model2d.Coord {
+	offsets := make([]model2d.Coord, len(l.lines))
+	var all []Contour
+	for i, contours := range byLine {
+		offsets[i] = model2d.XY(l.lines[i].baseline, 0)
+		if len(contours) == 0 {
+			continue
+		}
+		_, minY, _, maxY := contourBounds(contours)
+		dy := computeAlignVertical(l.opt, minY, maxY, l.lines[i].advance)
+		translateContours(contours, l.lines[i].baseline, dy)
+		offsets[i].Y = dy
+		all = append(all, contours...)
+	}
+	if len(all) == 0 {
+		return offsets
+	}
+	minX, _, maxX, _ := contourBounds(all)
+	var dx float64
+	switch l.opt.Align.HAlign {
+	case HAlignLeft, HAlignEnd:
+		dx = -minX
+	case HAlignCenter:
+		dx = -(minX + maxX) / 2
+	case HAlignRight, HAlignStart, HAlignJustify:
+		// Columns start on the right.
+		dx = -maxX
+	default:
+		panic("unknown HAlign")
+	}
+	translateContours(all, dx, 0)
+	for i := range offsets {
+		offsets[i].X += dx
+	}
+	return offsets
+}
+
+// computeAlignVertical computes the y offset of a column, analogous to how
+// computeAlign treats the horizontal advance: VAlignTop and VAlignBaseline
+// keep the pen start at y=0, VAlignBottom moves the end of the advance
+// there, and VAlignCenter centers the column's outline bounds. The metric
+// modes are treated likewise, except that VAlignEmCenter centers the
+// advance.
+func computeAlignVertical(opt Options, minY, maxY, advance float64) float64 {
+	switch opt.Align.VAlign {
+	case VAlignTop, VAlignBaseline, VAlignAscender, VAlignCapHeight, VAlignXHeight:
+		return 0
+	case VAlignCenter:
+		return -(minY + maxY) / 2
+	case VAlignBottom, VAlignDescender:
+		return advance
+	case VAlignEmCenter:
+		return advance / 2
+	default:
+		panic("unknown VAlign")
+	}
+}
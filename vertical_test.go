@@ -0,0 +1,131 @@
+package textcurve
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestVerticalLayout(t *testing.T) {
+	font := parseTestFont(t)
+	opt := Options{Size: 10, Vertical: true}
+	layout, err := layoutText([]*ParsedFont{font}, "AB\nC", opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(layout.lines) != 2 {
+		t.Fatalf("expected 2 columns but got %d", len(layout.lines))
+	}
+	if layout.lines[1].baseline >= layout.lines[0].baseline {
+		t.Errorf("second column should be left of the first: %f, %f", layout.lines[0].baseline,
+			layout.lines[1].baseline)
+	}
+	prevBottom := math.Inf(1)
+	for _, g := range layout.lines[0].glyphs {
+		contours, ok := g.contours(layout.opt.CurveSegs)
+		if !ok || len(contours) == 0 {
+			t.Fatal("missing glyph")
+		}
+		minX, minY, maxX, maxY := contourBounds(contours)
+		if center := (minX + maxX) / 2; math.Abs(center) > 0.5 {
+			t.Errorf("glyph %d is not centered on the column: %f", g.index, center)
+		}
+		if maxY > 0 || maxY >= prevBottom || minY >= maxY {
+			t.Errorf("glyph %d is not below the previous glyph: %f-%f", g.index, minY, maxY)
+		}
+		prevBottom = minY
+	}
+
+	// Sideways text is the horizontal text rotated clockwise.
+	horizontal, err := TextOutlines(font, "Hello", Options{Size: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sideways, err := TextOutlines(font, "Hello", Options{Size: 10, Vertical: true, Sideways: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hMin, hMax := outlinesBounds(horizontal)
+	sMin, sMax := outlinesBounds(sideways)
+	if math.Abs((sMax.X-sMin.X)-(hMax.Y-hMin.Y)) > 1e-6 || math.Abs((sMax.Y-sMin.Y)-(hMax.X-hMin.X)) > 1e-6 {
+		t.Errorf("sideways bounds %v-%v do not match horizontal bounds %v-%v", sMin, sMax, hMin, hMax)
+	}
+	if sMax.Y > 0 {
+		t.Errorf("sideways text should start at the top: %v", sMax)
+	}
+}
+
+func TestVerticalFeatures(t *testing.T) {
+	fontBytes, err := os.ReadFile(filepath.Join("test_data", "LiberationSans-Regular.ttf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	index := func(tag string, vertical bool, features []Feature) int {
+		// Make the feature substitute 'a' with 'b'.
+		font, err := ParseTTF(addTestTables(fontBytes, sfntTable{"GSUB", buildTestGSUB(tag, 68, 69)}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		layout, err := layoutText([]*ParsedFont{font}, "a",
+			Options{Size: 10, Vertical: vertical, Features: features})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return int(layout.lines[0].glyphs[0].index)
+	}
+
+	// Upright glyphs get the shaper's default 'vert', while 'vrt2' must be
+	// requested explicitly.
+	if idx := index("vert", true, nil); idx != 69 {
+		t.Errorf("vert should apply to vertical text, got glyph %d", idx)
+	}
+	if idx := index("vert", false, nil); idx != 68 {
+		t.Errorf("vert should not apply to horizontal text, got glyph %d", idx)
+	}
+	if idx := index("vrt2", true, nil); idx != 68 {
+		t.Errorf("vrt2 should not apply by default, got glyph %d", idx)
+	}
+	if idx := index("vrt2", true, []Feature{{Tag: "vrt2", Value: 1}}); idx != 69 {
+		t.Errorf("vrt2 should apply when requested, got glyph %d", idx)
+	}
+}
+
+func TestVerticalAlign(t *testing.T) {
+	font := parseTestFont(t)
+	layout, err := layoutText([]*ParsedFont{font}, "ABC", Options{Size: 10, Vertical: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	advance := layout.lines[0].advance
+
+	outlines := func(align Align) Outlines {
+		res, err := TextOutlines(font, "ABC", Options{Size: 10, Vertical: true, Align: align})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+	top := outlines(Align{HAlign: HAlignCenter, VAlign: VAlignTop})
+	assertSameBounds(t, "baseline", outlines(Align{HAlign: HAlignCenter, VAlign: VAlignBaseline}), top, 0)
+
+	bottomMin, _ := outlinesBounds(outlines(Align{HAlign: HAlignCenter, VAlign: VAlignBottom}))
+	topMin, _ := outlinesBounds(top)
+	if math.Abs(bottomMin.Y-(topMin.Y+advance)) > 1e-6 {
+		t.Errorf("bottom alignment should move the column up by its advance: %f vs %f",
+			bottomMin.Y, topMin.Y+advance)
+	}
+
+	centerMin, centerMax := outlinesBounds(outlines(Align{HAlign: HAlignCenter, VAlign: VAlignCenter}))
+	if math.Abs(centerMin.Y+centerMax.Y) > 1e-6 || math.Abs(centerMin.X+centerMax.X) > 1e-6 {
+		t.Errorf("expected centered bounds but got %v-%v", centerMin, centerMax)
+	}
+
+	leftMin, _ := outlinesBounds(outlines(Align{HAlign: HAlignLeft}))
+	_, rightMax := outlinesBounds(outlines(Align{HAlign: HAlignRight}))
+	_, startMax := outlinesBounds(outlines(Align{HAlign: HAlignStart}))
+	if math.Abs(leftMin.X) > 1e-6 || math.Abs(rightMax.X) > 1e-6 || math.Abs(startMax.X) > 1e-6 {
+		t.Errorf("unexpected horizontal alignment: left %f, right %f, start %f", leftMin.X,
+			rightMax.X, startMax.X)
+	}
+}
@@ -0,0 +1,616 @@
+package textcurve
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/andybalholm/brotli"
+)
+
+// decodeWebFont converts WOFF and WOFF2 containers into plain sfnt data.
+// Any other data is returned unchanged.
+func decodeWebFont(data []byte) ([]byte, error) {
+	if len(data) < 4 {
+		return data, nil
+	}
+	switch string(data[:4]) {
+	case "wOFF":
+		return decodeWOFF(data)
+	case "wOF2":
+		return decodeWOFF2(data)
+	}
+	return data, nil
+}
+
+// decodeWOFF decodes a WOFF 1.0 file, whose tables are individually
+// compressed with zlib.
+func decodeWOFF(data []byte) ([]byte, error) {
+	const (
+		headerSize = 44
+		entrySize  = 20
+	)
+	if len(data) < headerSize {
+		return nil, errors.New("woff: header is too short")
+	}
+	flavor := binary.BigEndian.Uint32(data[4:8])
+	numTables := int(binary.BigEndian.Uint16(data[12:14]))
+	if len(data) < headerSize+numTables*entrySize {
+		return nil, errors.New("woff: table directory is too short")
+	}
+
+	tables := make([]sfntTable, numTables)
+	for i := range tables {
+		entry := data[headerSize+i*entrySize:]
+		tag := string(entry[0:4])
+		offset := int(binary.BigEndian.Uint32(entry[4:8]))
+		compLength := int(binary.BigEndian.Uint32(entry[8:12]))
+		origLength := int(binary.BigEndian.Uint32(entry[12:16]))
+		if offset < 0 || compLength < 0 || offset+compLength > len(data) || compLength > origLength {
+			return nil, fmt.Errorf("woff: bad table entry for %q", tag)
+		}
+		raw := data[offset : offset+compLength]
+		if compLength < origLength {
+			zr, err := zlib.NewReader(bytes.NewReader(raw))
+			if err != nil {
+				return nil, fmt.Errorf("woff: decompress %q: %w", tag, err)
+			}
+			raw, err = io.ReadAll(io.LimitReader(zr, int64(origLength)+1))
+			if err != nil {
+				return nil, fmt.Errorf("woff: decompress %q: %w", tag, err)
+			}
+			if len(raw) != origLength {
+				return nil, fmt.Errorf("woff: bad decompressed length for %q", tag)
+			}
+		}
+		tables[i] = sfntTable{tag: tag, data: raw}
+	}
+
+	return writeSFNT([]sfntFace{{flavor: flavor, tables: allTableIndices(numTables)}}, tables, false), nil
+}
+
+// woff2KnownTags maps the 6-bit tag index of a WOFF2 table entry to a tag.
+var woff2KnownTags = [63]string{
+	"cmap", "head", "hhea", "hmtx", "maxp", "name", "OS/2", "post",
+	"cvt ", "fpgm", "glyf", "loca", "prep", "CFF ", "VORG", "EBDT",
+	"EBLC", "gasp", "hdmx", "kern", "LTSH", "PCLT", "VDMX", "vhea",
+	"vmtx", "BASE", "GDEF", "GPOS", "GSUB", "EBSC", "JSTF", "MATH",
+	"CBDT", "CBLC", "COLR", "CPAL", "SVG ", "sbix", "acnt", "avar",
+	"bdat", "bloc", "bsln", "cvar", "fdsc", "feat", "fmtx", "fvar",
+	"gvar", "hsty", "just", "lcar", "mort", "morx", "opbd", "prop",
+	"trak", "Zapf", "Silf", "Glat", "Gloc", "Feat", "Sill",
+}
+
+type woff2Entry struct {
+	tag         string
+	origLength  int
+	length      int // length in the decompressed stream
+	transformed bool
+
+	// xMins holds per-glyph xMin values once a glyf table is reconstructed.
+	xMins []int16
+}
+
+// decodeWOFF2 decodes a WOFF2 file, undoing the Brotli compression and the
+// glyf/loca and hmtx transforms.
+func decodeWOFF2(data []byte) ([]byte, error) {
+	const headerSize = 48
+	if len(data) < headerSize {
+		return nil, errors.New("woff2: header is too short")
+	}
+	flavor := binary.BigEndian.Uint32(data[4:8])
+	numTables := int(binary.BigEndian.Uint16(data[12:14]))
+	totalCompressedSize := int(binary.BigEndian.Uint32(data[20:24]))
+
+	r := &woff2Reader{data: data, pos: headerSize}
+	entries := make([]woff2Entry, numTables)
+	for i := range entries {
+		flags := r.u8()
+		var tag string
+		if idx := flags & 0x3f; idx == 0x3f {
+			tag = string(r.bytes(4))
+		} else {
+			tag = woff2KnownTags[idx]
+		}
+		version := flags >> 6
+		entry := woff2Entry{tag: tag, origLength: r.base128()}
+		if tag == "glyf" || tag == "loca" {
+			entry.transformed = version == 0
+		} else if version != 0 {
+			if tag != "hmtx" || version != 1 {
+				return nil, fmt.Errorf("woff2: unsupported transform %d for %q", version, tag)
+			}
+			entry.transformed = true
+		}
+		entry.length = entry.origLength
+		if entry.transformed {
+			entry.length = r.base128()
+		}
+		entries[i] = entry
+	}
+
+	collection := flavor == 0x74746366 // "ttcf"
+	var faces []sfntFace
+	if collection {
+		r.u32() // collection version
+		faces = make([]sfntFace, r.uint255())
+		for i := range faces {
+			faces[i].tables = make([]int, r.uint255())
+			faces[i].flavor = r.u32()
+			for j := range faces[i].tables {
+				idx := r.uint255()
+				if idx >= numTables {
+					return nil, errors.New("woff2: bad collection table index")
+				}
+				faces[i].tables[j] = idx
+			}
+		}
+	} else {
+		faces = []sfntFace{{flavor: flavor, tables: allTableIndices(numTables)}}
+	}
+	compressed := r.bytes(totalCompressedSize)
+	if r.err != nil {
+		return nil, fmt.Errorf("woff2: %w", r.err)
+	}
+
+	totalLength := 0
+	for _, e := range entries {
+		totalLength += e.length
+	}
+	stream, err := io.ReadAll(io.LimitReader(brotli.NewReader(bytes.NewReader(compressed)),
+		int64(totalLength)+1))
+	if err != nil {
+		return nil, fmt.Errorf("woff2: decompress: %w", err)
+	}
+	if len(stream) != totalLength {
+		return nil, errors.New("woff2: bad decompressed length")
+	}
+
+	tables := make([]sfntTable, numTables)
+	offset := 0
+	for i, e := range entries {
+		tables[i] = sfntTable{tag: e.tag, data: stream[offset : offset+e.length]}
+		offset += e.length
+	}
+
+	for _, face := range faces {
+		if err := reconstructWOFF2Face(entries, tables, face); err != nil {
+			return nil, err
+		}
+	}
+
+	return writeSFNT(faces, tables, collection), nil
+}
+
+// reconstructWOFF2Face undoes the table transforms used by a face.
+// Tables shared between faces of a collection are only reconstructed once.
+func reconstructWOFF2Face(entries []woff2Entry, tables []sfntTable, face sfntFace) error {
+	find := func(tag string) int {
+		for _, idx := range face.tables {
+			if tables[idx].tag == tag {
+				return idx
+			}
+		}
+		return -1
+	}
+	glyfIdx, locaIdx, hmtxIdx := find("glyf"), find("loca"), find("hmtx")
+
+	var xMins []int16
+	if glyfIdx >= 0 && entries[glyfIdx].transformed {
+		if locaIdx < 0 {
+			return errors.New("woff2: transformed glyf table without loca")
+		}
+		glyf, loca, mins, err := reconstructGlyf(tables[glyfIdx].data)
+		if err != nil {
+			return err
+		}
+		if len(loca) != entries[locaIdx].origLength {
+			return errors.New("woff2: reconstructed loca has unexpected length")
+		}
+		tables[glyfIdx].data, tables[locaIdx].data = glyf, loca
+		entries[glyfIdx].transformed, entries[locaIdx].transformed = false, false
+		entries[glyfIdx].xMins = mins
+	}
+	if glyfIdx >= 0 {
+		xMins = entries[glyfIdx].xMins
+	}
+
+	if hmtxIdx >= 0 && entries[hmtxIdx].transformed {
+		hheaIdx, maxpIdx := find("hhea"), find("maxp")
+		if hheaIdx < 0 || maxpIdx < 0 || len(tables[hheaIdx].data) < 36 || len(tables[maxpIdx].data) < 6 {
+			return errors.New("woff2: transformed hmtx requires hhea and maxp")
+		}
+		numHMetrics := int(binary.BigEndian.Uint16(tables[hheaIdx].data[34:36]))
+		numGlyphs := int(binary.BigEndian.Uint16(tables[maxpIdx].data[4:6]))
+		hmtx, err := reconstructHmtx(tables[hmtxIdx].data, numGlyphs, numHMetrics, xMins)
+		if err != nil {
+			return err
+		}
+		tables[hmtxIdx].data = hmtx
+		entries[hmtxIdx].transformed = false
+	}
+	return nil
+}
+
+// reconstructGlyf rebuilds the glyf and loca tables from a transformed glyf
+// table, also returning the xMin of every glyph for hmtx reconstruction.
+func reconstructGlyf(data []byte) (glyf, loca []byte, xMins []int16, err error) {
+	const (
+		headerSize = 36
+
+		flagOnCurve      = 0x01
+		flagXShort       = 0x02
+		flagYShort       = 0x04
+		flagXSameOrPos   = 0x10
+		flagYSameOrPos   = 0x20
+		flagOverlap      = 0x40
+		compArgsAreWords = 0x0001
+		compHaveScale    = 0x0008
+		compMore         = 0x0020
+		compXYScale      = 0x0040
+		compTwoByTwo     = 0x0080
+		compInstructions = 0x0100
+	)
+	if len(data) < headerSize {
+		return nil, nil, nil, errors.New("woff2: glyf header is too short")
+	}
+	optionFlags := binary.BigEndian.Uint16(data[2:4])
+	numGlyphs := int(binary.BigEndian.Uint16(data[4:6]))
+	indexFormat := binary.BigEndian.Uint16(data[6:8])
+
+	// Split the table into its substreams.
+	var streams [7]*woff2Reader
+	offset := headerSize
+	for i := range streams {
+		size := int(binary.BigEndian.Uint32(data[8+i*4:]))
+		if size < 0 || offset+size > len(data) {
+			return nil, nil, nil, errors.New("woff2: bad glyf stream size")
+		}
+		streams[i] = &woff2Reader{data: data[offset : offset+size]}
+		offset += size
+	}
+	nContourStream, nPointsStream, flagStream, glyphStream := streams[0], streams[1], streams[2], streams[3]
+	compositeStream, bboxStream, instructionStream := streams[4], streams[5], streams[6]
+
+	var overlapBitmap []byte
+	if optionFlags&1 != 0 {
+		size := (numGlyphs + 7) / 8
+		if offset+size > len(data) {
+			return nil, nil, nil, errors.New("woff2: overlap bitmap is too short")
+		}
+		overlapBitmap = data[offset : offset+size]
+	}
+	bboxBitmap := bboxStream.bytes(4 * ((numGlyphs + 31) / 32))
+	hasBBox := func(i int) bool {
+		return bboxBitmap != nil && bboxBitmap[i>>3]&(0x80>>(i&7)) != 0
+	}
+
+	var out bytes.Buffer
+	locaOffsets := make([]int, numGlyphs+1)
+	xMins = make([]int16, numGlyphs)
+	writeU16 := func(v uint16) {
+		out.WriteByte(byte(v >> 8))
+		out.WriteByte(byte(v))
+	}
+
+	for i := 0; i < numGlyphs; i++ {
+		locaOffsets[i] = out.Len()
+		nContours := int16(nContourStream.u16())
+
+		switch {
+		case nContours == 0:
+			if hasBBox(i) {
+				return nil, nil, nil, errors.New("woff2: empty glyph with bounding box")
+			}
+		case nContours < 0:
+			if !hasBBox(i) {
+				return nil, nil, nil, errors.New("woff2: composite glyph without bounding box")
+			}
+			bbox := bboxStream.bytes(8)
+
+			start := compositeStream.pos
+			haveInstructions := false
+			for {
+				flags := compositeStream.u16()
+				size := 2 + 2 + 2
+				if flags&compArgsAreWords != 0 {
+					size += 2
+				}
+				if flags&compHaveScale != 0 {
+					size += 2
+				} else if flags&compXYScale != 0 {
+					size += 4
+				} else if flags&compTwoByTwo != 0 {
+					size += 8
+				}
+				compositeStream.bytes(size - 2)
+				haveInstructions = haveInstructions || flags&compInstructions != 0
+				if flags&compMore == 0 || compositeStream.err != nil {
+					break
+				}
+			}
+			composite := compositeStream.data[start:compositeStream.pos]
+
+			writeU16(uint16(nContours))
+			out.Write(bbox)
+			out.Write(composite)
+			if haveInstructions {
+				n := glyphStream.uint255()
+				writeU16(uint16(n))
+				out.Write(instructionStream.bytes(n))
+			}
+			xMins[i] = int16(binary.BigEndian.Uint16(bbox))
+		default:
+			endPts := make([]uint16, nContours)
+			numPoints := 0
+			for j := range endPts {
+				numPoints += nPointsStream.uint255()
+				endPts[j] = uint16(numPoints - 1)
+			}
+			flags := flagStream.bytes(numPoints)
+			if flags == nil && numPoints > 0 {
+				return nil, nil, nil, errors.New("woff2: flag stream is too short")
+			}
+
+			xs := make([]int, numPoints)
+			ys := make([]int, numPoints)
+			onCurve := make([]bool, numPoints)
+			x, y := 0, 0
+			for j, flag := range flags {
+				dx, dy := decodeWOFF2Triplet(flag&0x7f, glyphStream)
+				x += dx
+				y += dy
+				xs[j], ys[j], onCurve[j] = x, y, flag&0x80 == 0
+			}
+			instructions := instructionStream.bytes(glyphStream.uint255())
+
+			var xMin, yMin, xMax, yMax int
+			if hasBBox(i) {
+				bbox := bboxStream.bytes(8)
+				if bbox != nil {
+					xMin = int(int16(binary.BigEndian.Uint16(bbox[0:])))
+					yMin = int(int16(binary.BigEndian.Uint16(bbox[2:])))
+					xMax = int(int16(binary.BigEndian.Uint16(bbox[4:])))
+					yMax = int(int16(binary.BigEndian.Uint16(bbox[6:])))
+				}
+			} else if numPoints > 0 {
+				xMin, yMin, xMax, yMax = xs[0], ys[0], xs[0], ys[0]
+				for j := range xs {
+					xMin, xMax = min(xMin, xs[j]), max(xMax, xs[j])
+					yMin, yMax = min(yMin, ys[j]), max(yMax, ys[j])
+				}
+			}
+
+			writeU16(uint16(nContours))
+			for _, v := range []int{xMin, yMin, xMax, yMax} {
+				writeU16(uint16(int16(v)))
+			}
+			for _, e := range endPts {
+				writeU16(e)
+			}
+			writeU16(uint16(len(instructions)))
+			out.Write(instructions)
+
+			var xData, yData bytes.Buffer
+			prevX, prevY := 0, 0
+			for j := range xs {
+				var flag byte
+				if onCurve[j] {
+					flag |= flagOnCurve
+				}
+				if j == 0 && overlapBitmap != nil && overlapBitmap[i>>3]&(0x80>>(i&7)) != 0 {
+					flag |= flagOverlap
+				}
+				dx, dy := xs[j]-prevX, ys[j]-prevY
+				prevX, prevY = xs[j], ys[j]
+				switch {
+				case dx == 0:
+					flag |= flagXSameOrPos
+				case dx > -256 && dx < 256:
+					flag |= flagXShort
+					if dx > 0 {
+						flag |= flagXSameOrPos
+					} else {
+						dx = -dx
+					}
+					xData.WriteByte(byte(dx))
+				default:
+					xData.Write([]byte{byte(dx >> 8), byte(dx)})
+				}
+				switch {
+				case dy == 0:
+					flag |= flagYSameOrPos
+				case dy > -256 && dy < 256:
+					flag |= flagYShort
+					if dy > 0 {
+						flag |= flagYSameOrPos
+					} else {
+						dy = -dy
+					}
+					yData.WriteByte(byte(dy))
+				default:
+					yData.Write([]byte{byte(dy >> 8), byte(dy)})
+				}
+				out.WriteByte(flag)
+			}
+			out.Write(xData.Bytes())
+			out.Write(yData.Bytes())
+			xMins[i] = int16(xMin)
+		}
+
+		for out.Len()%4 != 0 {
+			out.WriteByte(0)
+		}
+	}
+	locaOffsets[numGlyphs] = out.Len()
+
+	for _, s := range streams {
+		if s.err != nil {
+			return nil, nil, nil, fmt.Errorf("woff2: glyf: %w", s.err)
+		}
+	}
+
+	if indexFormat == 0 {
+		loca = make([]byte, 2*(numGlyphs+1))
+		for i, off := range locaOffsets {
+			binary.BigEndian.PutUint16(loca[i*2:], uint16(off/2))
+		}
+	} else {
+		loca = make([]byte, 4*(numGlyphs+1))
+		for i, off := range locaOffsets {
+			binary.BigEndian.PutUint32(loca[i*4:], uint32(off))
+		}
+	}
+	return out.Bytes(), loca, xMins, nil
+}
+
+// decodeWOFF2Triplet decodes one point delta of a simple glyph, given the
+// point's flag byte (without the on-curve bit).
+func decodeWOFF2Triplet(flag byte, r *woff2Reader) (dx, dy int) {
+	withSign := func(flag byte, v int) int {
+		if flag&1 != 0 {
+			return v
+		}
+		return -v
+	}
+	f := int(flag)
+	switch {
+	case flag < 10:
+		b := int(r.u8())
+		return 0, withSign(flag, ((f&14)<<7)+b)
+	case flag < 20:
+		b := int(r.u8())
+		return withSign(flag, (((f-10)&14)<<7)+b), 0
+	case flag < 84:
+		b0, b1 := f-20, int(r.u8())
+		return withSign(flag, 1+(b0&0x30)+(b1>>4)), withSign(flag>>1, 1+((b0&0x0c)<<2)+(b1&0x0f))
+	case flag < 120:
+		b0 := f - 84
+		b1, b2 := int(r.u8()), int(r.u8())
+		return withSign(flag, 1+((b0/12)<<8)+b1), withSign(flag>>1, 1+(((b0%12)>>2)<<8)+b2)
+	case flag < 124:
+		b1, b2, b3 := int(r.u8()), int(r.u8()), int(r.u8())
+		return withSign(flag, (b1<<4)+(b2>>4)), withSign(flag>>1, ((b2&0x0f)<<8)+b3)
+	default:
+		b1, b2, b3, b4 := int(r.u8()), int(r.u8()), int(r.u8()), int(r.u8())
+		return withSign(flag, (b1<<8)+b2), withSign(flag>>1, (b3<<8)+b4)
+	}
+}
+
+// reconstructHmtx rebuilds an hmtx table whose left side bearings were
+// omitted in favor of the glyphs' xMin values.
+func reconstructHmtx(data []byte, numGlyphs, numHMetrics int, xMins []int16) ([]byte, error) {
+	if numHMetrics < 1 || numHMetrics > numGlyphs || len(xMins) < numGlyphs {
+		return nil, errors.New("woff2: bad hmtx parameters")
+	}
+	r := &woff2Reader{data: data}
+	flags := r.u8()
+	advances := make([]uint16, numHMetrics)
+	for i := range advances {
+		advances[i] = r.u16()
+	}
+	lsbs := make([]uint16, numGlyphs)
+	for i := range lsbs {
+		explicit := flags&1 == 0
+		if i >= numHMetrics {
+			explicit = flags&2 == 0
+		}
+		if explicit {
+			lsbs[i] = r.u16()
+		} else {
+			lsbs[i] = uint16(xMins[i])
+		}
+	}
+	if r.err != nil {
+		return nil, fmt.Errorf("woff2: hmtx: %w", r.err)
+	}
+
+	res := make([]byte, 0, numHMetrics*4+(numGlyphs-numHMetrics)*2)
+	for i, lsb := range lsbs {
+		if i < numHMetrics {
+			res = binary.BigEndian.AppendUint16(res, advances[i])
+		}
+		res = binary.BigEndian.AppendUint16(res, lsb)
+	}
+	return res, nil
+}
+
+// woff2Reader reads the variable-length integers used by WOFF2.
+// After the first out-of-bounds read, err is set and all reads return zero.
+type woff2Reader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (r *woff2Reader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || r.pos+n > len(r.data) {
+		r.err = io.ErrUnexpectedEOF
+		return nil
+	}
+	res := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return res
+}
+
+func (r *woff2Reader) u8() uint8 {
+	if b := r.bytes(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (r *woff2Reader) u16() uint16 {
+	if b := r.bytes(2); b != nil {
+		return binary.BigEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (r *woff2Reader) u32() uint32 {
+	if b := r.bytes(4); b != nil {
+		return binary.BigEndian.Uint32(b)
+	}
+	return 0
+}
+
+// uint255 reads a 255UInt16 value.
+func (r *woff2Reader) uint255() int {
+	switch code := r.u8(); code {
+	case 253:
+		return int(r.u16())
+	case 254:
+		return int(r.u8()) + 506
+	case 255:
+		return int(r.u8()) + 253
+	default:
+		return int(code)
+	}
+}
+
+// base128 reads a UIntBase128 value.
+func (r *woff2Reader) base128() int {
+	var res uint32
+	for i := 0; i < 5; i++ {
+		b := r.u8()
+		if r.err != nil {
+			return 0
+		}
+		if (i == 0 && b == 0x80) || res&0xfe000000 != 0 {
+			r.err = errors.New("bad UIntBase128 value")
+			return 0
+		}
+		res = res<<7 | uint32(b&0x7f)
+		if b&0x80 == 0 {
+			return int(res)
+		}
+	}
+	r.err = errors.New("UIntBase128 value is too long")
+	return 0
+}
@@ -0,0 +1,486 @@
+package textcurve
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+)
+
+func TestParseWebFonts(t *testing.T) {
+	fontBytes, err := os.ReadFile(filepath.Join("test_data", "LiberationSans-Regular.ttf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Accented letters are composite glyphs.
+	const text = "Sp gj ÅéŁ"
+	opt := Options{Size: 10, CurveSegs: 4, Kerning: true}
+	expectedGlyphs, err := TextGlyphs(parseTestFont(t), text, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var expected Outlines
+	for _, g := range expectedGlyphs {
+		expected = append(expected, g.Outlines...)
+	}
+
+	for name, data := range map[string][]byte{
+		"woff":              encodeTestWOFF(fontBytes),
+		"woff2":             encodeTestWOFF2(fontBytes, false),
+		"woff2 transformed": encodeTestWOFF2(fontBytes, true),
+	} {
+		font, err := ParseTTF(data)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if font.hbFace == nil {
+			t.Errorf("%s: missing shaping face", name)
+		}
+		glyphs, err := TextGlyphs(font, text, opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var actual Outlines
+		for i, g := range glyphs {
+			if g.Pen != expectedGlyphs[i].Pen || g.Advance != expectedGlyphs[i].Advance {
+				t.Fatalf("%s: position mismatch at glyph %d", name, i)
+			}
+			actual = append(actual, g.Outlines...)
+		}
+		if len(actual) != len(expected) {
+			t.Fatalf("%s: expected %d contours, got %d", name, len(expected), len(actual))
+		}
+		for i := range actual {
+			for j := range actual[i] {
+				if actual[i][j] != expected[i][j] {
+					t.Fatalf("%s: outline mismatch at contour %d", name, i)
+				}
+			}
+		}
+
+		// Tables which are not affected by glyph padding come back intact.
+		decoded, err := decodeWebFont(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, tag := range []string{"hmtx", "cmap", "GPOS"} {
+			original, _ := findSFNTTable(fontBytes, 0, tag)
+			table, _ := findSFNTTable(decoded, 0, tag)
+			if !bytes.Equal(original, table) {
+				t.Errorf("%s: %q table mismatch", name, tag)
+			}
+		}
+	}
+}
+
+func TestReconstructGlyf(t *testing.T) {
+	streams := [][]byte{
+		{0, 0, 0, 1},                // nContour
+		{3},                         // nPoints
+		{0, 11, 126},                // flags
+		{0, 100, 0, 100, 0, 100, 0}, // glyphs
+		{},                          // composite
+		{0, 0, 0, 0},                // bbox
+		{},                          // instructions
+	}
+	data := []byte{0, 0, 0, 0, 0, 2, 0, 0}
+	for _, s := range streams {
+		data = binary.BigEndian.AppendUint32(data, uint32(len(s)))
+	}
+	for _, s := range streams {
+		data = append(data, s...)
+	}
+
+	glyf, loca, xMins, err := reconstructGlyf(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedGlyf := []byte{
+		0, 1, 0, 0, 0, 0, 0, 100, 0, 100, // header
+		0, 2, 0, 0, // end points and instruction length
+		0x31, 0x33, 0x27, // flags
+		100, 100, 100, // coordinates
+	}
+	if !bytes.Equal(glyf, expectedGlyf) {
+		t.Errorf("unexpected glyf: %v", glyf)
+	}
+	if !bytes.Equal(loca, []byte{0, 0, 0, 0, 0, 10}) {
+		t.Errorf("unexpected loca: %v", loca)
+	}
+	if len(xMins) != 2 || xMins[0] != 0 || xMins[1] != 0 {
+		t.Errorf("unexpected xMins: %v", xMins)
+	}
+}
+
+func encodeTestWOFF(font []byte) []byte {
+	numTables := int(binary.BigEndian.Uint16(font[4:6]))
+	header := make([]byte, 44+20*numTables)
+	copy(header, "wOFF")
+	copy(header[4:8], font[0:4])
+	binary.BigEndian.PutUint16(header[12:], uint16(numTables))
+
+	res := header
+	for i := 0; i < numTables; i++ {
+		rec := font[12+16*i:]
+		offset := binary.BigEndian.Uint32(rec[8:])
+		length := binary.BigEndian.Uint32(rec[12:])
+		var buf bytes.Buffer
+		w := zlib.NewWriter(&buf)
+		w.Write(font[offset : offset+length])
+		w.Close()
+		data := buf.Bytes()
+		if len(data) >= int(length) {
+			data = font[offset : offset+length]
+		}
+
+		entry := res[44+20*i:]
+		copy(entry[0:4], rec[0:4])
+		binary.BigEndian.PutUint32(entry[4:], uint32(len(res)))
+		binary.BigEndian.PutUint32(entry[8:], uint32(len(data)))
+		binary.BigEndian.PutUint32(entry[12:], length)
+		res = append(res, data...)
+		for len(res)%4 != 0 {
+			res = append(res, 0)
+		}
+	}
+	return res
+}
+
+// encodeTestWOFF2 builds a WOFF2 file, optionally applying the glyf/loca
+// and hmtx transforms.
+func encodeTestWOFF2(font []byte, transform bool) []byte {
+	numTables := int(binary.BigEndian.Uint16(font[4:6]))
+	var transformedGlyf, transformedHmtx []byte
+	if transform {
+		transformedGlyf, transformedHmtx = transformTestGlyf(font)
+	}
+
+	var directory, stream bytes.Buffer
+	for i := 0; i < numTables; i++ {
+		rec := font[12+16*i:]
+		tag := string(rec[0:4])
+		offset := binary.BigEndian.Uint32(rec[8:])
+		length := binary.BigEndian.Uint32(rec[12:])
+		data := font[offset : offset+length]
+
+		flags := byte(0x3f)
+		for j, known := range woff2KnownTags {
+			if known == tag {
+				flags = byte(j)
+			}
+		}
+		var transformed []byte
+		switch {
+		case tag == "glyf" && transform:
+			transformed = transformedGlyf
+		case tag == "loca" && transform:
+			transformed = []byte{}
+		case tag == "hmtx" && transformedHmtx != nil:
+			transformed = transformedHmtx
+			flags |= 1 << 6
+		case tag == "glyf" || tag == "loca":
+			flags |= 3 << 6 // null transform
+		}
+		directory.WriteByte(flags)
+		if flags&0x3f == 0x3f {
+			directory.WriteString(tag)
+		}
+		writeTestBase128(&directory, int(length))
+		if transformed != nil {
+			writeTestBase128(&directory, len(transformed))
+			data = transformed
+		}
+		stream.Write(data)
+	}
+
+	var compressed bytes.Buffer
+	w := brotli.NewWriter(&compressed)
+	w.Write(stream.Bytes())
+	w.Close()
+
+	header := make([]byte, 48)
+	copy(header, "wOF2")
+	copy(header[4:8], font[0:4])
+	binary.BigEndian.PutUint16(header[12:], uint16(numTables))
+	binary.BigEndian.PutUint32(header[20:], uint32(compressed.Len()))
+	res := append(header, directory.Bytes()...)
+	return append(res, compressed.Bytes()...)
+}
+
+func writeTestBase128(w *bytes.Buffer, v int) {
+	for shift := 28; shift > 0; shift -= 7 {
+		if v>>shift != 0 {
+			w.WriteByte(byte(v>>shift) | 0x80)
+		}
+	}
+	w.WriteByte(byte(v & 0x7f))
+}
+
+func writeTestUint255(w *bytes.Buffer, v int) {
+	switch {
+	case v < 253:
+		w.WriteByte(byte(v))
+	case v < 506:
+		w.Write([]byte{255, byte(v - 253)})
+	case v < 762:
+		w.Write([]byte{254, byte(v - 506)})
+	default:
+		w.Write([]byte{253, byte(v >> 8), byte(v)})
+	}
+}
+
+// transformTestGlyf applies the WOFF2 glyf transform to a font, and the
+// hmtx transform if its left side bearings match the glyphs' xMin.
+func transformTestGlyf(font []byte) (glyfTable, hmtxTable []byte) {
+	head, _ := findSFNTTable(font, 0, "head")
+	indexFormat := binary.BigEndian.Uint16(head[50:52])
+	glyphs := testGlyphs(font)
+	numGlyphs := len(glyphs)
+
+	var nContourStream, nPointsStream, flagStream, glyphStream bytes.Buffer
+	var compositeStream, bboxes, instructionStream bytes.Buffer
+	bboxBitmap := make([]byte, 4*((numGlyphs+31)/32))
+	xMins := make([]int16, numGlyphs)
+	u16 := func(b []byte, off int) int {
+		return int(binary.BigEndian.Uint16(b[off:]))
+	}
+	i16 := func(b []byte, off int) int {
+		return int(int16(binary.BigEndian.Uint16(b[off:])))
+	}
+
+	for i, data := range glyphs {
+		if len(data) == 0 {
+			nContourStream.Write([]byte{0, 0})
+			continue
+		}
+		nContours := i16(data, 0)
+		xMins[i] = int16(i16(data, 2))
+		nContourStream.Write(data[0:2])
+		if nContours < 0 {
+			bboxBitmap[i>>3] |= 0x80 >> (i & 7)
+			bboxes.Write(data[2:10])
+			pos := 10
+			haveInstructions := false
+			for {
+				flags := u16(data, pos)
+				size := 6
+				if flags&0x0001 != 0 {
+					size += 2
+				}
+				if flags&0x0008 != 0 {
+					size += 2
+				} else if flags&0x0040 != 0 {
+					size += 4
+				} else if flags&0x0080 != 0 {
+					size += 8
+				}
+				compositeStream.Write(data[pos : pos+size])
+				pos += size
+				haveInstructions = haveInstructions || flags&0x0100 != 0
+				if flags&0x0020 == 0 {
+					break
+				}
+			}
+			if haveInstructions {
+				n := u16(data, pos)
+				writeTestUint255(&glyphStream, n)
+				instructionStream.Write(data[pos+2 : pos+2+n])
+			}
+			continue
+		}
+
+		glyph := decodeTestSimpleGlyph(data)
+		numPoints := 0
+		for _, end := range glyph.endPoints {
+			writeTestUint255(&nPointsStream, end+1-numPoints)
+			numPoints = end + 1
+		}
+		x, y := 0, 0
+		for j := range glyph.x {
+			dx, dy := glyph.x[j]-x, glyph.y[j]-y
+			encodeTestTriplet(&flagStream, &glyphStream, dx, dy, glyph.onCurve[j])
+			x, y = glyph.x[j], glyph.y[j]
+		}
+		xMin, yMin, xMax, yMax := glyph.x[0], glyph.y[0], glyph.x[0], glyph.y[0]
+		for j := range glyph.x {
+			xMin, yMin = min(xMin, glyph.x[j]), min(yMin, glyph.y[j])
+			xMax, yMax = max(xMax, glyph.x[j]), max(yMax, glyph.y[j])
+		}
+		// Bounding boxes are only stored when they differ from the points.
+		if xMin != i16(data, 2) || yMin != i16(data, 4) || xMax != i16(data, 6) || yMax != i16(data, 8) {
+			bboxBitmap[i>>3] |= 0x80 >> (i & 7)
+			bboxes.Write(data[2:10])
+		}
+		writeTestUint255(&glyphStream, len(glyph.instructions))
+		instructionStream.Write(glyph.instructions)
+	}
+
+	header := make([]byte, 36)
+	binary.BigEndian.PutUint16(header[4:], uint16(numGlyphs))
+	binary.BigEndian.PutUint16(header[6:], indexFormat)
+	bboxStream := append(bboxBitmap, bboxes.Bytes()...)
+	streams := [][]byte{nContourStream.Bytes(), nPointsStream.Bytes(), flagStream.Bytes(),
+		glyphStream.Bytes(), compositeStream.Bytes(), bboxStream, instructionStream.Bytes()}
+	for i, s := range streams {
+		binary.BigEndian.PutUint32(header[8+4*i:], uint32(len(s)))
+	}
+	glyfTable = header
+	for _, s := range streams {
+		glyfTable = append(glyfTable, s...)
+	}
+
+	hmtx, _ := findSFNTTable(font, 0, "hmtx")
+	hhea, _ := findSFNTTable(font, 0, "hhea")
+	numHMetrics := u16(hhea, 34)
+	for i := 0; i < numGlyphs; i++ {
+		lsbOffset := 4*i + 2
+		if i >= numHMetrics {
+			lsbOffset = 4*numHMetrics + 2*(i-numHMetrics)
+		}
+		if i16(hmtx, lsbOffset) != int(xMins[i]) {
+			return glyfTable, nil
+		}
+	}
+	hmtxTable = []byte{3}
+	for i := 0; i < numHMetrics; i++ {
+		hmtxTable = append(hmtxTable, hmtx[4*i:4*i+2]...)
+	}
+	return glyfTable, hmtxTable
+}
+
+// testGlyphs returns the glyf data of every glyph in a font.
+func testGlyphs(font []byte) [][]byte {
+	glyf, _ := findSFNTTable(font, 0, "glyf")
+	loca, _ := findSFNTTable(font, 0, "loca")
+	head, _ := findSFNTTable(font, 0, "head")
+	maxp, _ := findSFNTTable(font, 0, "maxp")
+	indexFormat := binary.BigEndian.Uint16(head[50:52])
+	res := make([][]byte, binary.BigEndian.Uint16(maxp[4:6]))
+	for i := range res {
+		var start, end int
+		if indexFormat == 0 {
+			start = 2 * int(binary.BigEndian.Uint16(loca[2*i:]))
+			end = 2 * int(binary.BigEndian.Uint16(loca[2*i+2:]))
+		} else {
+			start = int(binary.BigEndian.Uint32(loca[4*i:]))
+			end = int(binary.BigEndian.Uint32(loca[4*i+4:]))
+		}
+		res[i] = glyf[start:end]
+	}
+	return res
+}
+
+type testSimpleGlyph struct {
+	endPoints    []int
+	instructions []byte
+	onCurve      []bool
+	x, y         []int
+}
+
+// decodeTestSimpleGlyph decodes the points of a simple glyf glyph.
+func decodeTestSimpleGlyph(data []byte) *testSimpleGlyph {
+	u16 := func(off int) int {
+		return int(binary.BigEndian.Uint16(data[off:]))
+	}
+	nContours := u16(0)
+	res := &testSimpleGlyph{}
+	pos := 10
+	for i := 0; i < nContours; i++ {
+		res.endPoints = append(res.endPoints, u16(pos))
+		pos += 2
+	}
+	numPoints := 0
+	if nContours > 0 {
+		numPoints = res.endPoints[nContours-1] + 1
+	}
+	numInstructions := u16(pos)
+	res.instructions = data[pos+2 : pos+2+numInstructions]
+	pos += 2 + numInstructions
+
+	flags := make([]byte, 0, numPoints)
+	for len(flags) < numPoints {
+		flag := data[pos]
+		pos++
+		flags = append(flags, flag)
+		if flag&0x08 != 0 {
+			for n := int(data[pos]); n > 0; n-- {
+				flags = append(flags, flag)
+			}
+			pos++
+		}
+	}
+	readCoords := func(short, sameOrPos byte) []int {
+		res := make([]int, numPoints)
+		value := 0
+		for i, flag := range flags {
+			switch {
+			case flag&short != 0:
+				if flag&sameOrPos != 0 {
+					value += int(data[pos])
+				} else {
+					value -= int(data[pos])
+				}
+				pos++
+			case flag&sameOrPos == 0:
+				value += int(int16(u16(pos)))
+				pos += 2
+			}
+			res[i] = value
+		}
+		return res
+	}
+	res.x = readCoords(0x02, 0x10)
+	res.y = readCoords(0x04, 0x20)
+	for _, flag := range flags {
+		res.onCurve = append(res.onCurve, flag&1 != 0)
+	}
+	return res
+}
+
+// encodeTestTriplet encodes a point delta of a simple glyph like the
+// reference WOFF2 encoder.
+func encodeTestTriplet(flags, glyphs *bytes.Buffer, dx, dy int, onCurve bool) {
+	absX, absY := dx, dy
+	var xSign, ySign int
+	if dx >= 0 {
+		xSign = 1
+	} else {
+		absX = -dx
+	}
+	if dy >= 0 {
+		ySign = 1
+	} else {
+		absY = -dy
+	}
+	xySign := xSign + 2*ySign
+
+	var flag int
+	switch {
+	case dx == 0 && absY < 1280:
+		flag = ((absY & 0xf00) >> 7) + ySign
+		glyphs.WriteByte(byte(absY))
+	case dy == 0 && absX < 1280:
+		flag = 10 + ((absX & 0xf00) >> 7) + xSign
+		glyphs.WriteByte(byte(absX))
+	case absX < 65 && absY < 65:
+		flag = 20 + ((absX - 1) & 0x30) + (((absY - 1) & 0x30) >> 2) + xySign
+		glyphs.WriteByte(byte(((absX-1)&0xf)<<4 | ((absY - 1) & 0xf)))
+	case absX < 769 && absY < 769:
+		flag = 84 + 12*(((absX-1)&0x300)>>8) + (((absY - 1) & 0x300) >> 6) + xySign
+		glyphs.Write([]byte{byte(absX - 1), byte(absY - 1)})
+	case absX < 4096 && absY < 4096:
+		flag = 120 + xySign
+		glyphs.Write([]byte{byte(absX >> 4), byte((absX&0xf)<<4 | absY>>8), byte(absY)})
+	default:
+		flag = 124 + xySign
+		glyphs.Write([]byte{byte(absX >> 8), byte(absX), byte(absY >> 8), byte(absY)})
+	}
+	if !onCurve {
+		flag |= 0x80
+	}
+	flags.WriteByte(byte(flag))
+}
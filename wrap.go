@@ -0,0 +1,82 @@
+package textcurve
+
+import (
+	"unicode"
+
+	"github.com/go-text/typesetting/segmenter"
+)
+
+// wrapLine greedily breaks a line at UAX #14 line break opportunities so
+// that each resulting line is at most maxWidth wide.
+//
+// advances holds the advance of each rune in model units, as measured by
+// shaping the whole line. Trailing whitespace does not count towards the
+// width of a line, and is removed from wrapped lines. Segments which do not
+// fit on a line of their own are broken between grapheme clusters, keeping
+// at least one cluster per line.
+func wrapLine(runes []rune, advances []float64, maxWidth float64) [][]rune {
+	width := func(start, end int) float64 {
+		for end > start && unicode.IsSpace(runes[end-1]) {
+			end--
+		}
+		var w float64
+		for _, a := range advances[start:end] {
+			w += a
+		}
+		return w
+	}
+
+	var seg segmenter.Segmenter
+	seg.Init(runes)
+	var graphemeEnds []int
+	graphemes := seg.GraphemeIterator()
+	for graphemes.Next() {
+		g := graphemes.Grapheme()
+		graphemeEnds = append(graphemeEnds, g.Offset+len(g.Text))
+	}
+
+	var res [][]rune
+	lineStart, lineEnd := 0, 0
+	flush := func() {
+		end := lineEnd
+		for end > lineStart && unicode.IsSpace(runes[end-1]) {
+			end--
+		}
+		res = append(res, runes[lineStart:end])
+		lineStart = lineEnd
+	}
+
+	iter := seg.LineIterator()
+	for iter.Next() {
+		segment := iter.Line()
+		segEnd := segment.Offset + len(segment.Text)
+		if width(lineStart, segEnd) <= maxWidth {
+			lineEnd = segEnd
+			continue
+		}
+		if lineEnd > lineStart {
+			flush()
+			if width(lineStart, segEnd) <= maxWidth {
+				lineEnd = segEnd
+				continue
+			}
+		}
+
+		// The segment is too long for a line of its own.
+		for _, gEnd := range graphemeEnds {
+			if gEnd <= lineEnd {
+				continue
+			} else if gEnd > segEnd {
+				break
+			}
+			if lineEnd > lineStart && width(lineStart, gEnd) > maxWidth {
+				flush()
+			}
+			lineEnd = gEnd
+		}
+	}
+	if lineEnd > lineStart || len(res) == 0 {
+		flush()
+	}
+	return res
+}
@@ -0,0 +1,71 @@
+package textcurve
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWrapLine(t *testing.T) {
+	cases := []struct {
+		text     string
+		maxWidth float64
+		expected []string
+	}{
+		{"aa bb cc", 5, []string{"aa bb", "cc"}},
+		{"aa bb cc", 100, []string{"aa bb cc"}},
+		{"aa   bb", 2, []string{"aa", "bb"}},
+		{"abcdefgh ij", 3, []string{"abc", "def", "gh", "ij"}},
+		{"x abcdefg", 4, []string{"x", "abcd", "efg"}},
+		{"日本語の文章", 2, []string{"日本", "語の", "文章"}},
+		{"ab-cd", 3, []string{"ab-", "cd"}},
+		{"", 1, []string{""}},
+	}
+	for _, c := range cases {
+		runes := []rune(c.text)
+		advances := make([]float64, len(runes))
+		for i := range advances {
+			advances[i] = 1
+		}
+		var actual []string
+		for _, line := range wrapLine(runes, advances, c.maxWidth) {
+			actual = append(actual, string(line))
+		}
+		if !reflect.DeepEqual(actual, c.expected) {
+			t.Errorf("%q (max %f): expected %q but got %q", c.text, c.maxWidth, c.expected, actual)
+		}
+	}
+}
+
+func TestMaxWidth(t *testing.T) {
+	font := parseTestFont(t)
+	opt := Options{Size: 10, Kerning: true}
+	unwrapped, err := TextOutlines(font, "The quick brown fox jumps over the lazy dog", opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opt.MaxWidth = 60
+	wrapped, err := TextOutlines(font, "The quick brown fox jumps over the lazy dog", opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(wrapped) != len(unwrapped) {
+		t.Errorf("expected %d contours but got %d", len(unwrapped), len(wrapped))
+	}
+	min, max := outlinesBounds(wrapped)
+	if max.X-min.X > opt.MaxWidth {
+		t.Errorf("wrapped width %f exceeds %f", max.X-min.X, opt.MaxWidth)
+	}
+	if max.Y-min.Y < 2*opt.Size {
+		t.Errorf("expected several lines but got height %f", max.Y-min.Y)
+	}
+
+	// Wrapping matches explicit line breaks at the same positions.
+	explicit, err := TextOutlines(font, "The quick\nbrown fox\njumps\nover the\nlazy dog", Options{
+		Size:    10,
+		Kerning: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertSameBounds(t, "wrapped", wrapped, explicit, 0)
+}